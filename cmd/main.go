@@ -2,41 +2,111 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"flag"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 	"time"
 
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/config"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/events"
 	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/handlers"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/queue"
 	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/routes"
 	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/store"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/store/memstore"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/store/sqlitestore"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/util"
 	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/worker"
 )
 
 func main() {
-	st, err := store.NewFileStore("./data")
+	cfg, err := config.Load()
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	mgr := worker.NewManager(st, 5)
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(cfg, os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "worker" {
+		runWorker(cfg, os.Args[2:])
+		return
+	}
+
+	st, err := openStore(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer st.Close()
+
+	hub := events.NewHub()
+	st.SetNotifier(hub)
+
+	//один limiter на обработчик и worker, чтобы лимиты по хостам были общими
+	checker := newChecker(cfg)
+
+	q, err := openQueue(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer q.Close()
+
+	mgr := worker.NewManager(st, cfg.Workers, checker,
+		worker.WithQueue(q),
+		worker.WithSetTimeout(cfg.SetTimeout),
+		worker.WithShutdownGrace(cfg.ShutdownGrace),
+		worker.WithMaxDeliveries(cfg.MaxDeliveries),
+		worker.WithLeaseTTL(cfg.LeaseTTL),
+	)
 	go mgr.Run()
 
-	h := handlers.NewHandler(st, mgr)
+	scaler := worker.NewAutoscaler(mgr, autoscalePolicy(cfg))
+	go scaler.Run()
+
+	sched := worker.NewScheduler(st, mgr, time.Second)
+	go sched.Run()
+
+	janitor := store.NewJanitor(st, store.RetentionPolicy{
+		MaxAge:              cfg.RetentionMaxAge,
+		MaxCount:            cfg.RetentionMaxCount,
+		KeepLastPerSchedule: cfg.RetentionPerSchedule,
+	}, cfg.PurgeInterval)
+	go janitor.Run()
+
+	h := handlers.NewHandler(st, mgr, checker, handlers.WithEvents(hub), handlers.WithJanitor(janitor), handlers.WithQueue(q),
+		handlers.WithWorkers(mgr, scaler), handlers.WithRemoteWorkers(mgr, cfg.WorkerToken),
+		handlers.WithAdminToken(cfg.AdminToken))
 	router := routes.NewRouter(h)
 
+	//Shutdown не отменяет контексты запросов: поток событий, ожидание аренды и синхронные проверки
+	//прерываются отменой base, прерванный синхронный набор уже стоит в очереди worker
+	base, cancelRequests := context.WithCancel(context.Background())
 	srv := &http.Server{
-		Addr:    ":8080",
-		Handler: router,
+		Addr:        cfg.Addr,
+		Handler:     router,
+		BaseContext: func(net.Listener) context.Context { return base },
 	}
 
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
 
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
 	go func() {
-		log.Println("Server started on :8080")
+		for range hup {
+			reload(mgr, scaler)
+		}
+	}()
+
+	go func() {
+		log.Printf("Server started on %s (storage: %s)", cfg.Addr, cfg.Storage)
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("ListenAndServe: %v", err)
 		}
@@ -45,14 +115,135 @@ func main() {
 	<-stop
 	log.Println("Shutting down server...")
 
+	cancelRequests()
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
 	if err := srv.Shutdown(ctx); err != nil {
-		log.Fatalf("Server Shutdown Failed:%+v", err)
+		log.Printf("Server Shutdown Failed:%+v", err) //worker и хранилище все равно останавливаются
 	}
 
+	sched.Stop()
+	janitor.Stop()
+	scaler.Stop()
 	mgr.Stop()
 
 	log.Println("Server exited gracefully")
 }
+
+func newChecker(cfg config.Config) util.Checker {
+	limiter := util.NewHostLimiter(cfg.HostLimit, cfg.HostDelay)
+	return util.Chain(util.NewHTTPChecker(), util.WithHostLimit(limiter), util.WithTimeout(cfg.LinkTimeout))
+}
+
+func openStore(cfg config.Config) (store.Store, error) {
+	switch cfg.Storage {
+	case config.StorageSQLite:
+		if err := os.MkdirAll(filepath.Dir(cfg.SQLitePath), 0o755); err != nil {
+			return nil, err
+		}
+		return sqlitestore.New(cfg.SQLitePath)
+	case config.StorageMemory:
+		return memstore.New(), nil
+	default:
+		return store.NewFileStore(cfg.DataDir)
+	}
+}
+
+func autoscalePolicy(cfg config.Config) worker.AutoscalePolicy {
+	return worker.AutoscalePolicy{
+		Min:        cfg.AutoscaleMin,
+		Max:        cfg.AutoscaleMax,
+		Interval:   cfg.AutoscaleInterval,
+		MaxLatency: cfg.AutoscaleMaxLatency,
+	}
+}
+
+// reload перечитывает конфигурацию по SIGHUP и применяет размер пула worker и автоскейлинг.
+// Остальные настройки требуют перезапуска; при ошибке конфигурации остаются старые значения
+func reload(mgr *worker.Manager, scaler *worker.Autoscaler) {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Printf("reload config: %v", err)
+		return
+	}
+
+	p := autoscalePolicy(cfg)
+	scaler.SetPolicy(p)
+	if !p.Enabled() { //при автоскейлинге размер пула выбирает Autoscaler
+		if err := mgr.Resize(cfg.Workers); err != nil {
+			log.Printf("reload config: %v", err)
+		}
+	}
+	log.Printf("config reloaded: workers %d, autoscale max %d", cfg.Workers, cfg.AutoscaleMax)
+}
+
+// openQueue - очередь worker: в памяти для storage memory, иначе журнал рядом с данными
+func openQueue(cfg config.Config) (queue.Queue, error) {
+	opts := []queue.Option{queue.WithCapacity(cfg.QueueCapacity), queue.WithVisibility(cfg.QueueVisibility)}
+	switch cfg.Storage {
+	case config.StorageMemory:
+		return queue.NewMemQueue(opts...), nil
+	case config.StorageSQLite:
+		return queue.OpenFileQueue(filepath.Join(filepath.Dir(cfg.SQLitePath), "queue.log"), opts...)
+	default:
+		return queue.OpenFileQueue(filepath.Join(cfg.DataDir, "queue.log"), opts...)
+	}
+}
+
+// runWorker - подкоманда worker -server URL [-name N] [-workers N] [-batch N]: удаленный worker,
+// берет ссылки у центрального сервиса и проверяет их из своей сети. Хранилище не нужно
+func runWorker(cfg config.Config, args []string) {
+	fl := flag.NewFlagSet("worker", flag.ExitOnError)
+	server := fl.String("server", "http://localhost:8080", "адрес центрального сервиса")
+	name := fl.String("name", "", "имя worker, по умолчанию hostname")
+	loops := fl.Int("workers", cfg.Workers, "сколько аренд проверять одновременно")
+	batch := fl.Int("batch", 50, "сколько ссылок брать в одну аренду")
+	fl.Parse(args)
+
+	opts := []worker.RemoteOption{worker.WithRemoteToken(cfg.WorkerToken), worker.WithBatch(*batch)}
+	if *name != "" {
+		opts = append(opts, worker.WithRemoteName(*name))
+	}
+	remote := worker.NewRemote(*server, *loops, newChecker(cfg), opts...)
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	go remote.Run()
+	log.Printf("Remote worker started, server %s", *server)
+
+	<-stop
+	log.Println("Stopping remote worker...")
+	remote.Stop()
+}
+
+// runMigrate - подкоманда migrate [-dry-run]: обновляет формат всех наборов файлового хранилища
+func runMigrate(cfg config.Config, args []string) {
+	fl := flag.NewFlagSet("migrate", flag.ExitOnError)
+	dryRun := fl.Bool("dry-run", false, "только показать, какие наборы будут обновлены")
+	fl.Parse(args)
+
+	if cfg.Storage != config.StorageFile {
+		log.Printf("storage %s: nothing to migrate", cfg.Storage)
+		return
+	}
+
+	fs, err := store.NewFileStore(cfg.DataDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer fs.Close()
+
+	report, err := fs.Migrate(*dryRun)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", " ")
+	enc.Encode(report)
+	if len(report.Failed) > 0 {
+		fs.Close()
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,416 @@
+package archive
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/store"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/store/migrate"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/models"
+)
+
+// Архив хранилища - tar.gz:
+//
+//	sets/<id>.json       - набор с результатами
+//	schedules/<id>.json  - расписание
+//	meta.json            - версия формата, время создания, число наборов и расписаний
+//	manifest.json        - размер и sha256 каждого файла, пишется последним
+//
+// История проверок url в архив не входит.
+
+const (
+	Version = 1
+
+	manifestName = "manifest.json"
+	metaName     = "meta.json"
+
+	maxFileSize = 64 << 20 //один набор не может быть таким большим, защита от испорченного архива
+
+	//архив распаковывается в память целиком: ограничения защищают от gzip-бомбы
+	DefaultMaxFiles     = 1 << 20
+	DefaultMaxTotalSize = 1 << 30 //все файлы после распаковки
+)
+
+// ErrInvalid - архив поврежден или не прошел проверку, хранилище не менялось
+var ErrInvalid = errors.New("invalid archive")
+
+type Meta struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	LastSetID int64     `json:"last_set_id"`
+	Sets      int       `json:"sets"`
+	Schedules int       `json:"schedules"`
+}
+
+type Manifest struct {
+	Version int         `json:"version"`
+	Files   []FileEntry `json:"files"`
+}
+
+type FileEntry struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// ImportReport - результат импорта. Renumbered - наборы, получившие новый id из-за совпадения
+type ImportReport struct {
+	Sets             int             `json:"sets"`
+	Schedules        int             `json:"schedules"`
+	Renumbered       map[int64]int64 `json:"renumbered"`
+	SkippedSchedules []int64         `json:"skipped_schedules,omitempty"` //набор-источник не найден в архиве
+	Unfinished       []int64         `json:"unfinished"`                  //новые id незавершенных наборов, их нужно поставить в очередь
+}
+
+// Export пишет все наборы и расписания st в w
+func Export(w io.Writer, st store.Store) (Meta, error) {
+	schedules, err := st.ListSchedules()
+	if err != nil {
+		return Meta{}, fmt.Errorf("list schedules: %v", err)
+	}
+	meta := Meta{Version: Version, CreatedAt: time.Now().UTC(), Schedules: len(schedules)}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	manifest := Manifest{Version: Version}
+
+	add := func(name string, v any) error {
+		b, err := json.MarshalIndent(v, "", " ")
+		if err != nil {
+			return err
+		}
+		if err := writeFile(tw, name, b, meta.CreatedAt); err != nil {
+			return fmt.Errorf("write %s: %v", name, err)
+		}
+		sum := sha256.Sum256(b)
+		manifest.Files = append(manifest.Files, FileEntry{Name: name, Size: int64(len(b)), SHA256: hex.EncodeToString(sum[:])})
+		return nil
+	}
+
+	//наборы пишутся по мере чтения страницами, meta.json с их числом - после них
+	err = store.Walk(st, func(s *models.LinkSet) error {
+		meta.Sets++
+		meta.LastSetID = max(meta.LastSetID, s.ID)
+		return add(fmt.Sprintf("sets/%d.json", s.ID), s)
+	})
+	if err != nil {
+		return meta, err
+	}
+	for _, sc := range schedules {
+		if err := add(fmt.Sprintf("schedules/%d.json", sc.ID), sc); err != nil {
+			return meta, err
+		}
+	}
+	if err := add(metaName, meta); err != nil {
+		return meta, err
+	}
+
+	b, _ := json.MarshalIndent(manifest, "", " ")
+	if err := writeFile(tw, manifestName, b, meta.CreatedAt); err != nil {
+		return meta, fmt.Errorf("write manifest: %v", err)
+	}
+
+	if err := tw.Close(); err != nil {
+		return meta, err
+	}
+	return meta, gz.Close()
+}
+
+func writeFile(tw *tar.Writer, name string, b []byte, mod time.Time) error {
+	hdr := &tar.Header{Name: name, Mode: 0o644, Size: int64(len(b)), ModTime: mod, Typeflag: tar.TypeReg}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := tw.Write(b)
+	return err
+}
+
+type limits struct {
+	files int
+	size  int64
+}
+
+type ImportOption func(*limits)
+
+// WithLimits - сколько файлов и байт после распаковки может быть в архиве, по умолчанию
+// DefaultMaxFiles и DefaultMaxTotalSize. Архив больше лимита отклоняется как ErrInvalid
+func WithLimits(files int, size int64) ImportOption {
+	return func(l *limits) {
+		if files > 0 {
+			l.files = files
+		}
+		if size > 0 {
+			l.size = size
+		}
+	}
+}
+
+// Import проверяет архив целиком и только потом добавляет наборы и расписания в st.
+// id набора сохраняется, если свободен, иначе store выдает новый; ссылки расписаний
+// и запусков на наборы и расписания пересчитываются под новые id. Если запись в st
+// прервалась ошибкой, уже добавленные наборы и расписания удаляются
+func Import(r io.Reader, st store.Store, opts ...ImportOption) (ImportReport, error) {
+	report := ImportReport{Renumbered: map[int64]int64{}, Unfinished: []int64{}}
+
+	lim := limits{files: DefaultMaxFiles, size: DefaultMaxTotalSize}
+	for _, opt := range opts {
+		opt(&lim)
+	}
+
+	files, err := read(r, lim)
+	if err != nil {
+		return report, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	sets, schedules, err := decode(files)
+	if err != nil {
+		return report, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+
+	setIDs := map[int64]int64{}      //id в архиве -> id в хранилище
+	scheduleIDs := map[int64]int64{} //то же для расписаний
+	undo := func(err error) error {
+		rollback(st, setIDs, scheduleIDs)
+		return err
+	}
+
+	importSet := func(s *models.LinkSet) error {
+		old := s.ID
+		id, err := st.ImportSet(s)
+		if err != nil {
+			return fmt.Errorf("import set %d: %v", old, err)
+		}
+		setIDs[old] = id
+		if id != old {
+			report.Renumbered[old] = id
+		}
+		if !s.Status.IsFinished() {
+			report.Unfinished = append(report.Unfinished, id)
+		}
+		report.Sets++
+		return nil
+	}
+
+	importSchedule := func(sc models.Schedule) error {
+		old := sc.ID
+		src, ok := setIDs[sc.SetID]
+		if !ok {
+			report.SkippedSchedules = append(report.SkippedSchedules, old)
+			return nil
+		}
+		sc.SetID = src
+		created, err := st.CreateSchedule(sc) //id расписаний всегда новые
+		if err != nil {
+			return fmt.Errorf("import schedule %d: %v", old, err)
+		}
+		scheduleIDs[old] = created.ID
+		report.Schedules++
+		return nil
+	}
+
+	//порядок: обычные наборы, расписания (им нужны id наборов), запуски расписаний (им нужны id расписаний)
+	var runs []*models.LinkSet
+	for _, s := range sets {
+		if s.ScheduleID != 0 {
+			runs = append(runs, s)
+			continue
+		}
+		if err := importSet(s); err != nil {
+			return report, undo(err)
+		}
+	}
+
+	var deferred []models.Schedule //источник - запуск другого расписания
+	for _, sc := range schedules {
+		if _, ok := setIDs[sc.SetID]; !ok {
+			deferred = append(deferred, sc)
+			continue
+		}
+		if err := importSchedule(sc); err != nil {
+			return report, undo(err)
+		}
+	}
+
+	for _, s := range runs {
+		s.ScheduleID = scheduleIDs[s.ScheduleID] //0, если расписание не импортировано
+		if err := importSet(s); err != nil {
+			return report, undo(err)
+		}
+	}
+	for _, sc := range deferred {
+		if err := importSchedule(sc); err != nil {
+			return report, undo(err)
+		}
+	}
+
+	return report, nil
+}
+
+// rollback удаляет добавленные импортом расписания и наборы, ошибки только логируются
+func rollback(st store.Store, setIDs, scheduleIDs map[int64]int64) {
+	for _, id := range scheduleIDs {
+		if err := st.DeleteSchedule(id); err != nil {
+			log.Printf("import rollback: delete schedule %d: %v", id, err)
+		}
+	}
+	for _, id := range setIDs {
+		if err := st.DeleteSet(id); err != nil {
+			log.Printf("import rollback: delete set %d: %v", id, err)
+		}
+	}
+}
+
+// read распаковывает архив и проверяет файлы по manifest.json. Число записей и размер
+// распакованных файлов проверяются до чтения каждого файла, а не по итогу
+func read(r io.Reader, lim limits) (map[string][]byte, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	files := map[string][]byte{}
+	var (
+		entries int
+		total   int64
+	)
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if entries++; entries > lim.files {
+			return nil, fmt.Errorf("more than %d files", lim.files)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		name := path.Clean(hdr.Name)
+		if hdr.Size > maxFileSize {
+			return nil, fmt.Errorf("%s is too large", name)
+		}
+		if total+hdr.Size > lim.size {
+			return nil, fmt.Errorf("more than %d bytes unpacked", lim.size)
+		}
+		if _, ok := files[name]; ok {
+			return nil, fmt.Errorf("duplicate %s", name)
+		}
+		b, err := io.ReadAll(io.LimitReader(tr, hdr.Size))
+		if err != nil {
+			return nil, err
+		}
+		total += int64(len(b))
+		files[name] = b
+	}
+
+	mb, ok := files[manifestName]
+	if !ok {
+		return nil, errors.New("no manifest.json")
+	}
+	delete(files, manifestName)
+
+	var manifest Manifest
+	if err := json.Unmarshal(mb, &manifest); err != nil {
+		return nil, fmt.Errorf("bad manifest: %v", err)
+	}
+	if manifest.Version != Version {
+		return nil, fmt.Errorf("unsupported archive version %d", manifest.Version)
+	}
+
+	listed := make(map[string]bool, len(manifest.Files))
+	for _, fe := range manifest.Files {
+		b, ok := files[fe.Name]
+		if !ok {
+			return nil, fmt.Errorf("%s listed in manifest is missing", fe.Name)
+		}
+		sum := sha256.Sum256(b)
+		if int64(len(b)) != fe.Size || hex.EncodeToString(sum[:]) != fe.SHA256 {
+			return nil, fmt.Errorf("checksum mismatch for %s", fe.Name)
+		}
+		listed[fe.Name] = true
+	}
+	for name := range files {
+		if !listed[name] {
+			return nil, fmt.Errorf("%s is not listed in manifest", name)
+		}
+	}
+	return files, nil
+}
+
+// decode разбирает наборы и расписания, наборы по возрастанию id
+func decode(files map[string][]byte) ([]*models.LinkSet, []models.Schedule, error) {
+	var meta Meta
+	if err := json.Unmarshal(files[metaName], &meta); err != nil {
+		return nil, nil, fmt.Errorf("bad meta.json: %v", err)
+	}
+
+	var (
+		sets      []*models.LinkSet
+		schedules []models.Schedule
+	)
+	for name, b := range files {
+		dir, base := path.Split(name)
+		id, err := strconv.ParseInt(strings.TrimSuffix(base, ".json"), 10, 64)
+
+		switch {
+		case name == metaName:
+			continue
+		case err != nil || !strings.HasSuffix(base, ".json"):
+			return nil, nil, fmt.Errorf("unexpected file %s", name)
+		case dir == "sets/":
+			b, _, err := migrate.Set(b) //архив мог быть снят старой версией сервиса
+			if err != nil {
+				return nil, nil, fmt.Errorf("bad %s: %v", name, err)
+			}
+			var s models.LinkSet
+			if err := json.Unmarshal(b, &s); err != nil {
+				return nil, nil, fmt.Errorf("bad %s: %v", name, err)
+			}
+			if s.ID != id || len(s.Links) == 0 {
+				return nil, nil, fmt.Errorf("bad %s: id or links", name)
+			}
+			for url, r := range s.Results {
+				if r == nil {
+					return nil, nil, fmt.Errorf("bad %s: empty result for %s", name, url)
+				}
+			}
+			sets = append(sets, &s)
+		case dir == "schedules/":
+			var sc models.Schedule
+			if err := json.Unmarshal(b, &sc); err != nil {
+				return nil, nil, fmt.Errorf("bad %s: %v", name, err)
+			}
+			if sc.ID != id {
+				return nil, nil, fmt.Errorf("bad %s: id", name)
+			}
+			schedules = append(schedules, sc)
+		default:
+			return nil, nil, fmt.Errorf("unexpected file %s", name)
+		}
+	}
+
+	if len(sets) != meta.Sets || len(schedules) != meta.Schedules {
+		return nil, nil, fmt.Errorf("meta.json lists %d sets and %d schedules, found %d and %d",
+			meta.Sets, meta.Schedules, len(sets), len(schedules))
+	}
+
+	sort.Slice(sets, func(i, j int) bool { return sets[i].ID < sets[j].ID })
+	sort.Slice(schedules, func(i, j int) bool { return schedules[i].ID < schedules[j].ID })
+	return sets, schedules, nil
+}
@@ -0,0 +1,171 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	StorageFile   = "file"
+	StorageSQLite = "sqlite"
+	StorageMemory = "memory" //данные не сохраняются между запусками
+)
+
+// Config - настройки сервиса, читаются из переменных окружения LINKCHECKER_*
+type Config struct {
+	Addr       string //LINKCHECKER_ADDR
+	Storage    string //LINKCHECKER_STORAGE: file, sqlite или memory
+	DataDir    string //LINKCHECKER_DATA_DIR, каталог FileStore
+	SQLitePath string //LINKCHECKER_SQLITE_PATH
+	Workers    int    //LINKCHECKER_WORKERS
+
+	RetentionMaxAge      time.Duration //LINKCHECKER_RETENTION_MAX_AGE, 0 - без ограничения
+	RetentionMaxCount    int           //LINKCHECKER_RETENTION_MAX_COUNT
+	RetentionPerSchedule int           //LINKCHECKER_RETENTION_KEEP_PER_SCHEDULE
+	PurgeInterval        time.Duration //LINKCHECKER_PURGE_INTERVAL
+
+	LinkTimeout   time.Duration //LINKCHECKER_LINK_TIMEOUT, дедлайн проверки одной ссылки
+	HostLimit     int           //LINKCHECKER_HOST_CONCURRENCY, сколько запросов к одному хосту одновременно
+	HostDelay     time.Duration //LINKCHECKER_HOST_DELAY, пауза между обращениями к одному хосту
+	SetTimeout    time.Duration //LINKCHECKER_SET_TIMEOUT, дедлайн проверки набора, 0 - без ограничения
+	ShutdownGrace time.Duration //LINKCHECKER_SHUTDOWN_GRACE, сколько ждать проверки при остановке
+
+	QueueCapacity   int           //LINKCHECKER_QUEUE_CAPACITY, сколько наборов может ждать проверки
+	QueueVisibility time.Duration //LINKCHECKER_QUEUE_VISIBILITY, через сколько неподтвержденный набор выдается снова
+	MaxDeliveries   int           //LINKCHECKER_MAX_DELIVERIES, после стольких неудачных выдач набор уходит в dead letter
+
+	AutoscaleMin        int           //LINKCHECKER_AUTOSCALE_MIN
+	AutoscaleMax        int           //LINKCHECKER_AUTOSCALE_MAX, 0 - размер пула задает только LINKCHECKER_WORKERS
+	AutoscaleInterval   time.Duration //LINKCHECKER_AUTOSCALE_INTERVAL
+	AutoscaleMaxLatency time.Duration //LINKCHECKER_AUTOSCALE_MAX_LATENCY, 0 - без ограничения
+
+	WorkerToken string        //LINKCHECKER_WORKER_TOKEN, токен api удаленных worker, пустой - api выключено
+	LeaseTTL    time.Duration //LINKCHECKER_LEASE_TTL, на сколько продлевается аренда удаленного worker
+
+	AdminToken string //LINKCHECKER_ADMIN_TOKEN, токен api /admin/*, пустой - api выключено
+}
+
+func Load() (Config, error) {
+	cfg := Config{
+		Addr:        env("LINKCHECKER_ADDR", ":8080"),
+		Storage:     env("LINKCHECKER_STORAGE", StorageFile),
+		DataDir:     env("LINKCHECKER_DATA_DIR", "./data"),
+		SQLitePath:  env("LINKCHECKER_SQLITE_PATH", "./data/linkchecker.db"),
+		WorkerToken: os.Getenv("LINKCHECKER_WORKER_TOKEN"),
+		AdminToken:  os.Getenv("LINKCHECKER_ADMIN_TOKEN"),
+	}
+
+	workers, err := strconv.Atoi(env("LINKCHECKER_WORKERS", "5"))
+	if err != nil || workers < 1 {
+		return cfg, fmt.Errorf("bad LINKCHECKER_WORKERS: %q", os.Getenv("LINKCHECKER_WORKERS"))
+	}
+	cfg.Workers = workers
+
+	if cfg.RetentionMaxAge, err = duration("LINKCHECKER_RETENTION_MAX_AGE", "0"); err != nil {
+		return cfg, err
+	}
+	if cfg.RetentionMaxCount, err = count("LINKCHECKER_RETENTION_MAX_COUNT"); err != nil {
+		return cfg, err
+	}
+	if cfg.RetentionPerSchedule, err = count("LINKCHECKER_RETENTION_KEEP_PER_SCHEDULE"); err != nil {
+		return cfg, err
+	}
+	if cfg.PurgeInterval, err = duration("LINKCHECKER_PURGE_INTERVAL", "1h"); err != nil {
+		return cfg, err
+	}
+	if cfg.PurgeInterval <= 0 {
+		return cfg, fmt.Errorf("bad LINKCHECKER_PURGE_INTERVAL: must be positive")
+	}
+	if cfg.LinkTimeout, err = duration("LINKCHECKER_LINK_TIMEOUT", "30s"); err != nil {
+		return cfg, err
+	}
+	hostLimit, err := strconv.Atoi(env("LINKCHECKER_HOST_CONCURRENCY", "4"))
+	if err != nil || hostLimit < 1 {
+		return cfg, fmt.Errorf("bad LINKCHECKER_HOST_CONCURRENCY: %q", os.Getenv("LINKCHECKER_HOST_CONCURRENCY"))
+	}
+	cfg.HostLimit = hostLimit
+	if cfg.HostDelay, err = duration("LINKCHECKER_HOST_DELAY", "200ms"); err != nil {
+		return cfg, err
+	}
+	if cfg.SetTimeout, err = duration("LINKCHECKER_SET_TIMEOUT", "0"); err != nil {
+		return cfg, err
+	}
+	if cfg.ShutdownGrace, err = duration("LINKCHECKER_SHUTDOWN_GRACE", "10s"); err != nil {
+		return cfg, err
+	}
+
+	capacity, err := strconv.Atoi(env("LINKCHECKER_QUEUE_CAPACITY", "10000"))
+	if err != nil || capacity < 1 {
+		return cfg, fmt.Errorf("bad LINKCHECKER_QUEUE_CAPACITY: %q", os.Getenv("LINKCHECKER_QUEUE_CAPACITY"))
+	}
+	cfg.QueueCapacity = capacity
+	if cfg.QueueVisibility, err = duration("LINKCHECKER_QUEUE_VISIBILITY", "5m"); err != nil {
+		return cfg, err
+	}
+	if cfg.QueueVisibility <= 0 {
+		return cfg, fmt.Errorf("bad LINKCHECKER_QUEUE_VISIBILITY: must be positive")
+	}
+	deliveries, err := strconv.Atoi(env("LINKCHECKER_MAX_DELIVERIES", "5"))
+	if err != nil || deliveries < 1 {
+		return cfg, fmt.Errorf("bad LINKCHECKER_MAX_DELIVERIES: %q", os.Getenv("LINKCHECKER_MAX_DELIVERIES"))
+	}
+	cfg.MaxDeliveries = deliveries
+
+	if cfg.AutoscaleMin, err = count("LINKCHECKER_AUTOSCALE_MIN"); err != nil {
+		return cfg, err
+	}
+	if cfg.AutoscaleMax, err = count("LINKCHECKER_AUTOSCALE_MAX"); err != nil {
+		return cfg, err
+	}
+	if cfg.AutoscaleMax > 0 && cfg.AutoscaleMin > cfg.AutoscaleMax {
+		return cfg, fmt.Errorf("bad LINKCHECKER_AUTOSCALE_MIN: %d is greater than LINKCHECKER_AUTOSCALE_MAX %d", cfg.AutoscaleMin, cfg.AutoscaleMax)
+	}
+	if cfg.AutoscaleInterval, err = duration("LINKCHECKER_AUTOSCALE_INTERVAL", "10s"); err != nil {
+		return cfg, err
+	}
+	if cfg.AutoscaleInterval <= 0 {
+		return cfg, fmt.Errorf("bad LINKCHECKER_AUTOSCALE_INTERVAL: must be positive")
+	}
+	if cfg.AutoscaleMaxLatency, err = duration("LINKCHECKER_AUTOSCALE_MAX_LATENCY", "0"); err != nil {
+		return cfg, err
+	}
+	if cfg.LeaseTTL, err = duration("LINKCHECKER_LEASE_TTL", "30s"); err != nil {
+		return cfg, err
+	}
+	if cfg.LeaseTTL <= 0 {
+		return cfg, fmt.Errorf("bad LINKCHECKER_LEASE_TTL: must be positive")
+	}
+
+	switch cfg.Storage {
+	case StorageFile, StorageSQLite, StorageMemory:
+	default:
+		return cfg, fmt.Errorf("unknown LINKCHECKER_STORAGE %q, expected %s, %s or %s", cfg.Storage, StorageFile, StorageSQLite, StorageMemory)
+	}
+	return cfg, nil
+}
+
+func duration(key, def string) (time.Duration, error) {
+	d, err := time.ParseDuration(env(key, def))
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("bad %s: %q", key, os.Getenv(key))
+	}
+	return d, nil
+}
+
+// count - неотрицательное число, 0 если переменная не задана
+func count(key string) (int, error) {
+	n, err := strconv.Atoi(env(key, "0"))
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("bad %s: %q", key, os.Getenv(key))
+	}
+	return n, nil
+}
+
+func env(key, def string) string {
+	if v, ok := os.LookupEnv(key); ok && v != "" {
+		return v
+	}
+	return def
+}
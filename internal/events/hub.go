@@ -0,0 +1,87 @@
+package events
+
+import (
+	"sync"
+
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/models"
+)
+
+const (
+	TypeResult = "result" //обновился результат ссылки
+	TypeDone   = "done"   //набор завершен: все ссылки проверены или проверка отменена
+)
+
+const subscriberBuffer = 64
+
+type Event struct {
+	Type   string             `json:"type"`
+	SetID  int64              `json:"set_id"`
+	URL    string             `json:"url,omitempty"`
+	Result *models.LinkResult `json:"result,omitempty"`
+	Status models.SetStatus   `json:"status,omitempty"`
+}
+
+// Hub - pub/sub для событий по наборам ссылок, подписка на конкретный id набора
+type Hub struct {
+	mu   sync.Mutex
+	subs map[int64]map[chan Event]struct{}
+}
+
+func NewHub() *Hub {
+	return &Hub{subs: make(map[int64]map[chan Event]struct{})}
+}
+
+// Subscribe подписывает на события набора id, cancel нужно вызвать после использования.
+// Если подписчик не успевает читать, канал закрывается
+func (h *Hub) Subscribe(id int64) (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+
+	h.mu.Lock()
+	if h.subs[id] == nil {
+		h.subs[id] = make(map[chan Event]struct{})
+	}
+	h.subs[id][ch] = struct{}{}
+	h.mu.Unlock()
+
+	cancel := func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		h.remove(id, ch)
+	}
+	return ch, cancel
+}
+
+// remove вызывается под h.mu
+func (h *Hub) remove(id int64, ch chan Event) {
+	subs := h.subs[id]
+	if _, ok := subs[ch]; !ok {
+		return
+	}
+	delete(subs, ch)
+	close(ch)
+	if len(subs) == 0 {
+		delete(h.subs, id)
+	}
+}
+
+// Publish рассылает событие подписчикам набора, не блокируется на медленных подписчиках
+func (h *Hub) Publish(e Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for ch := range h.subs[e.SetID] {
+		select {
+		case ch <- e:
+		default:
+			h.remove(e.SetID, ch) //клиент переподключится и получит актуальное состояние
+		}
+	}
+}
+
+// LinkUpdated вызывается store после сохранения результата ссылки
+func (h *Hub) LinkUpdated(set *models.LinkSet, url string, res models.LinkResult) {
+	h.Publish(Event{Type: TypeResult, SetID: set.ID, URL: url, Result: &res, Status: set.Status})
+	if set.Status.IsFinished() {
+		h.Publish(Event{Type: TypeDone, SetID: set.ID, Status: set.Status})
+	}
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/archive"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/queue"
+)
+
+const maxImportSize = 1 << 30
+
+// AdminAuth пропускает к api /admin/* только запросы с токеном администратора
+func (h *Handler) AdminAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.adminToken == "" {
+			h.respondError(w, http.StatusNotImplemented, "admin api disabled, set LINKCHECKER_ADMIN_TOKEN")
+			return
+		}
+		if !bearer(r, h.adminToken) {
+			h.respondError(w, http.StatusUnauthorized, "bad admin token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Purge - POST /admin/purge?dry_run=true, удаление старых наборов по политике хранения.
+// В ответе id удаленных наборов (при dry_run - тех, что были бы удалены)
+func (h *Handler) Purge(w http.ResponseWriter, r *http.Request) {
+	if h.janitor == nil {
+		h.respondError(w, http.StatusNotImplemented, "retention disabled")
+		return
+	}
+
+	var dryRun bool
+	if s := r.URL.Query().Get("dry_run"); s != "" {
+		v, err := strconv.ParseBool(s)
+		if err != nil {
+			h.respondError(w, http.StatusBadRequest, "bad dry_run")
+			return
+		}
+		dryRun = v
+	}
+
+	report, err := h.janitor.Purge(dryRun)
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.respondJSON(w, http.StatusOK, report)
+}
+
+// Export - GET /admin/export, архив tar.gz со всеми наборами и расписаниями
+func (h *Handler) Export(w http.ResponseWriter, r *http.Request) {
+	name := fmt.Sprintf("linkchecker-%s.tar.gz", time.Now().UTC().Format("20060102-150405"))
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+
+	//заголовки уже отправлены, ошибку можно только залогировать - архив без manifest.json не импортируется
+	if _, err := archive.Export(w, h.store); err != nil {
+		log.Printf("export: %v", err)
+	}
+}
+
+// Import - POST /admin/import, тело - архив из /admin/export. Наборы добавляются к существующим,
+// при совпадении id набор получает новый id; незавершенные наборы ставятся в очередь worker
+func (h *Handler) Import(w http.ResponseWriter, r *http.Request) {
+	body := http.MaxBytesReader(w, r.Body, maxImportSize)
+	defer body.Close()
+
+	report, err := archive.Import(body, h.store)
+	if err != nil {
+		code := http.StatusInternalServerError
+		if errors.Is(err, archive.ErrInvalid) {
+			code = http.StatusBadRequest
+		}
+		h.respondError(w, code, err.Error())
+		return
+	}
+
+	for _, id := range report.Unfinished {
+		if err := h.mgr.Enqueue(id, queue.PriorityBulk); err != nil {
+			log.Printf("import: enqueue set %d: %v", id, err)
+		}
+	}
+	h.respondJSON(w, http.StatusOK, report)
+}
+
+// QueueStats - GET /admin/queue, глубина очереди worker по каждому lane
+func (h *Handler) QueueStats(w http.ResponseWriter, r *http.Request) {
+	if h.queue == nil {
+		h.respondError(w, http.StatusNotImplemented, "queue stats disabled")
+		return
+	}
+	h.respondJSON(w, http.StatusOK, h.queue.Stats())
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/queue"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/store"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/models"
+)
+
+// ListDeadLetters - GET /admin/dead-letters, наборы, которые worker не смог обработать
+func (h *Handler) ListDeadLetters(w http.ResponseWriter, r *http.Request) {
+	list, err := h.store.ListDeadLetters()
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.respondJSON(w, http.StatusOK, map[string]any{"dead_letters": list, "total": len(list)})
+}
+
+// GetDeadLetter - GET /admin/dead-letters/{id}
+func (h *Handler) GetDeadLetter(w http.ResponseWriter, r *http.Request) {
+	d, ok := h.loadDeadLetter(w, r)
+	if !ok {
+		return
+	}
+	h.respondJSON(w, http.StatusOK, d)
+}
+
+// RequeueDeadLetter - POST /admin/dead-letters/{id}/requeue?priority=, набор снова ставится в очередь worker
+// и получает новый счетчик выдач
+func (h *Handler) RequeueDeadLetter(w http.ResponseWriter, r *http.Request) {
+	lane, err := queue.ParsePriority(r.URL.Query().Get("priority"))
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	d, ok := h.loadDeadLetter(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.store.RemoveDeadLetter(d.SetID); err != nil && !errors.Is(err, store.ErrNotFound) {
+		h.respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err := h.mgr.Enqueue(d.SetID, lane); err != nil {
+		h.store.DeadLetter(d) //набор не попал в очередь - оставляем его в dead letter
+		code := http.StatusInternalServerError
+		if errors.Is(err, queue.ErrFull) {
+			w.Header().Set("Retry-After", "30")
+			code = http.StatusServiceUnavailable
+		}
+		h.respondError(w, code, err.Error())
+		return
+	}
+
+	statusURL := fmt.Sprintf("/sets/%d", d.SetID)
+	w.Header().Set("Location", statusURL)
+	h.respondJSON(w, http.StatusAccepted, map[string]any{
+		"links_num":  d.SetID,
+		"priority":   lane,
+		"status_url": statusURL,
+	})
+}
+
+// DiscardDeadLetter - DELETE /admin/dead-letters/{id}, набор удаляется вместе с записью dead letter
+func (h *Handler) DiscardDeadLetter(w http.ResponseWriter, r *http.Request) {
+	d, ok := h.loadDeadLetter(w, r)
+	if !ok || !h.checkNotSource(w, d.SetID) {
+		return
+	}
+
+	if err := h.store.DeleteSet(d.SetID); err != nil && !errors.Is(err, store.ErrNotFound) {
+		h.respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) loadDeadLetter(w http.ResponseWriter, r *http.Request) (models.DeadLetter, bool) {
+	id, ok := h.pathID(w, r)
+	if !ok {
+		return models.DeadLetter{}, false
+	}
+
+	d, err := h.store.GetDeadLetter(id)
+	if errors.Is(err, store.ErrNotFound) {
+		h.respondError(w, http.StatusNotFound, "dead letter not found")
+		return d, false
+	}
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, err.Error())
+		return d, false
+	}
+	return d, true
+}
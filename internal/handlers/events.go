@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/events"
+)
+
+const keepAliveInterval = 15 * time.Second
+
+// SetEvents - GET /sets/{id}/events, поток Server-Sent Events с результатами ссылок.
+// Сначала отправляются уже готовые результаты, затем новые по мере проверки и событие done
+func (h *Handler) SetEvents(w http.ResponseWriter, r *http.Request) {
+	if h.events == nil {
+		h.respondError(w, http.StatusNotImplemented, "events disabled")
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		h.respondError(w, http.StatusInternalServerError, "streaming unsupported")
+		return
+	}
+
+	id, ok := h.pathID(w, r)
+	if !ok {
+		return
+	}
+
+	//подписываемся до чтения набора, чтобы не пропустить обновления между чтением и подпиской
+	ch, cancel := h.events.Subscribe(id)
+	defer cancel()
+
+	set, ok := h.loadSet(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	for _, url := range set.Links {
+		if res := set.Results[url]; res != nil {
+			writeEvent(w, events.Event{Type: events.TypeResult, SetID: id, URL: url, Result: res, Status: set.Status})
+		}
+	}
+	if set.Status.IsFinished() {
+		writeEvent(w, events.Event{Type: events.TypeDone, SetID: id, Status: set.Status})
+		flusher.Flush()
+		return
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		case e, ok := <-ch:
+			if !ok {
+				return //не успевали читать, клиент переподключится
+			}
+			writeEvent(w, e)
+			flusher.Flush()
+			if e.Type == events.TypeDone {
+				return
+			}
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, e events.Event) {
+	b, _ := json.Marshal(e)
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, b)
+}
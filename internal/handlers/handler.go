@@ -1,32 +1,101 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"strconv"
 	"sync"
-	"time"
 
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/events"
 	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/pdfgen"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/queue"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/store"
 	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/util"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/worker"
 	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/models"
 )
 
-type LinkCreator interface {
-	CreateSet([]string) (int64, *models.LinkSet, error)
-	UpdateLinkResult(int64, string, models.LinkResult) error
-	ListSets([]int64) ([]*models.LinkSet, error)
+type Handler struct {
+	store   store.Store
+	mgr     Manager
+	checker util.Checker
+	events  *events.Hub
+	janitor *store.Janitor
+	queue   queue.Queue
+	pool    *worker.Manager
+	scaler  *worker.Autoscaler
+
+	leases      *worker.Manager
+	workerToken string
+	adminToken  string
 }
 
-type Handler struct {
-	store LinkCreator
-	mgr   interface{ Enqueue(int64) } //worker ставит id набора ссылок в очередь
+// Manager - очередь worker и отмена наборов (worker.Manager)
+type Manager interface {
+	Enqueue(int64, queue.Priority) error //worker ставит id набора ссылок в очередь, queue.ErrFull - очередь заполнена
+	Track(ctx context.Context, id int64) (context.Context, func())
+	Record(id int64, url string, res models.LinkResult) error //сохраняет результат, ссылка для повтора остается processing
+	Cancel(id int64) (*models.LinkSet, error)
+}
+
+type Option func(*Handler)
+
+// WithEvents включает поток событий GET /sets/{id}/events
+func WithEvents(hub *events.Hub) Option {
+	return func(h *Handler) { h.events = hub }
+}
+
+// WithJanitor включает POST /admin/purge
+func WithJanitor(j *store.Janitor) Option {
+	return func(h *Handler) { h.janitor = j }
+}
+
+// WithQueue включает GET /admin/queue
+func WithQueue(q queue.Queue) Option {
+	return func(h *Handler) { h.queue = q }
+}
+
+// WithWorkers включает GET и PUT /admin/workers, scaler может быть nil
+func WithWorkers(pool *worker.Manager, scaler *worker.Autoscaler) Option {
+	return func(h *Handler) {
+		h.pool = pool
+		h.scaler = scaler
+	}
+}
+
+// WithRemoteWorkers включает api аренды для удаленных worker /worker/leases, запросы должны
+// передавать token в Authorization: Bearer. С пустым token api остается выключенным
+func WithRemoteWorkers(m *worker.Manager, token string) Option {
+	return func(h *Handler) {
+		if token == "" {
+			return
+		}
+		h.leases = m
+		h.workerToken = token
+	}
 }
 
-func NewHandler(s LinkCreator, mgr interface{ Enqueue(int64) }) *Handler {
-	return &Handler{store: s, mgr: mgr}
+// WithAdminToken включает api /admin/*, запросы должны передавать token в Authorization: Bearer.
+// Без токена api остается выключенным: экспорт и импорт отдают и меняют все хранилище
+func WithAdminToken(token string) Option {
+	return func(h *Handler) { h.adminToken = token }
+}
+
+// если checker nil, используется util.HTTPChecker
+func NewHandler(s store.Store, mgr Manager, checker util.Checker, opts ...Option) *Handler {
+	if checker == nil {
+		checker = util.NewHTTPChecker()
+	}
+	h := &Handler{store: s, mgr: mgr, checker: checker}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
 }
 
 func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
@@ -40,7 +109,21 @@ func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if raw, ok := body["links"]; ok { //ссылки
-		h.handleLinks(w, raw)
+		var async bool
+		if rawAsync, ok := body["async"]; ok {
+			if err := json.Unmarshal(rawAsync, &async); err != nil {
+				h.respondError(w, http.StatusBadRequest, "bad async flag")
+				return
+			}
+		}
+		var priority string
+		if rawPriority, ok := body["priority"]; ok {
+			if err := json.Unmarshal(rawPriority, &priority); err != nil {
+				h.respondError(w, http.StatusBadRequest, "bad priority")
+				return
+			}
+		}
+		h.handleLinks(w, r, raw, async, priority)
 		return
 	}
 
@@ -52,65 +135,115 @@ func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
 	h.respondError(w, http.StatusBadRequest, "bad payload")
 }
 
-func (h *Handler) handleLinks(w http.ResponseWriter, raw json.RawMessage) {
+func (h *Handler) handleLinks(w http.ResponseWriter, r *http.Request, raw json.RawMessage, async bool, priority string) {
 	var links []string
 	if err := json.Unmarshal(raw, &links); err != nil {
 		h.respondError(w, http.StatusBadRequest, "bad links format")
 		return
 	}
+	h.submitLinks(w, r, links, async, priority, http.StatusOK)
+}
+
+// submitLinks создает набор ссылок и проверяет его сразу (code - код ответа)
+// или ставит в очередь worker (async) с приоритетом priority
+func (h *Handler) submitLinks(w http.ResponseWriter, r *http.Request, links []string, async bool, priority string, code int) {
 	if len(links) == 0 {
 		h.respondError(w, http.StatusBadRequest, "нет ссылок")
 		return
 	}
+	lane, err := queue.ParsePriority(priority)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
-	id, _, err := h.store.CreateSet(links) //сохранение ссылок в filestore
+	id, set, err := h.store.CreateSet(links) //сохранение ссылок в filestore
 	if err != nil {
 		h.respondError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+
+	if async { //ссылки проверит worker, клиент узнает результат по status_url
+		if err := h.mgr.Enqueue(id, lane); err != nil {
+			h.store.DeleteSet(id)
+			code := http.StatusInternalServerError
+			if errors.Is(err, queue.ErrFull) {
+				w.Header().Set("Retry-After", "30")
+				code = http.StatusServiceUnavailable
+			}
+			h.respondError(w, code, err.Error())
+			return
+		}
+
+		statusURL := fmt.Sprintf("/sets/%d", id)
+		w.Header().Set("Location", statusURL)
+		h.respondJSON(w, http.StatusAccepted, map[string]any{
+			"links_num":  id,
+			"status":     set.Status,
+			"priority":   lane,
+			"status_url": statusURL,
+		})
+		return
+	}
+
+	//проверки прерываются отменой набора (POST /sets/{id}/cancel) или отключением клиента
+	ctx, done := h.mgr.Track(r.Context(), id)
+	defer done()
+
 	var wg sync.WaitGroup
 	mu := sync.Mutex{}
 	out := make(map[string]string)
+	results := make(map[string]models.LinkResult)
 
 	for _, url := range links {
 		wg.Add(1)
 		go func(u string) {
 			defer wg.Done()
-			ok, detail := util.CheckURL(u) // каждая ссылка проверяется в отдельной горутине
-			res := models.LinkResult{
-				URL:       u,
-				CheckedAt: time.Now(),
-				Detail:    detail,
-			}
-			if ok {
-				res.State = models.StateAvailable
-			} else {
-				res.State = models.StateNotAvailable
-			}
+			c := h.checker.Check(ctx, u) // каждая ссылка проверяется в отдельной горутине
+			res := util.ToLinkResult(u, c)
 
-			if err := h.store.UpdateLinkResult(id, u, res); err != nil {
-				log.Printf("update result: %v", err)
+			//прерванную проверку не сохраняем: ссылку пометит отмена набора или проверит worker
+			if res.State != models.StateCancelled {
+				if err := h.mgr.Record(id, u, res); err != nil && !errors.Is(err, store.ErrCancelled) {
+					log.Printf("update result: %v", err)
+				}
 			}
 
 			mu.Lock()
-			if ok {
-				out[u] = "available"
-			} else {
-				out[u] = "not available"
-			}
+			out[u] = res.State.Label()
+			results[u] = res
 			mu.Unlock()
 		}(url)
 	}
 
 	wg.Wait()
 
+	//id набора ставится в очередь на случай перезапуска сервиса или прерванных проверок
+	if err := h.mgr.Enqueue(id, lane); err != nil {
+		log.Printf("enqueue set %d: %v", id, err)
+		h.finalize(id, results)
+	}
+
 	resp := map[string]any{
 		"links":     out,
 		"links_num": id,
+		"results":   results, //подробности: статус, метод, итоговый url, время ответа
 	}
-	h.respondJSON(w, http.StatusOK, resp)
+	w.Header().Set("Location", fmt.Sprintf("/sets/%d", id))
+	h.respondJSON(w, code, resp)
+}
 
-	h.mgr.Enqueue(id) //id набора ставится в очередь на случай перезапуска сервиса
+// finalize записывает результаты синхронной проверки как итоговые, когда повтора worker не будет:
+// иначе ссылки, оставленные processing для повтора, не завершатся никогда
+func (h *Handler) finalize(id int64, results map[string]models.LinkResult) {
+	for url, res := range results {
+		if res.State == models.StateCancelled {
+			continue
+		}
+		if err := h.store.UpdateLinkResult(id, url, res); err != nil && !errors.Is(err, store.ErrCancelled) {
+			log.Printf("update result: %v", err)
+		}
+	}
 }
 
 func (h *Handler) handlePDF(w http.ResponseWriter, raw json.RawMessage) {
@@ -123,7 +256,11 @@ func (h *Handler) handlePDF(w http.ResponseWriter, raw json.RawMessage) {
 		return
 	}
 
-	buf, err := pdfgen.GeneratePDF(sets) //сгенерировать pdf
+	h.respondPDF(w, sets)
+}
+
+func (h *Handler) respondPDF(w http.ResponseWriter, sets []*models.LinkSet) {
+	buf, err := pdfgen.GeneratePDF(sets, h.reportStats(sets)) //сгенерировать pdf
 	if err != nil {
 		h.respondError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/worker"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/models"
+	"github.com/gorilla/mux"
+)
+
+const (
+	defaultLeaseWait = 20 * time.Second
+	maxLeaseWait     = time.Minute
+)
+
+// Lease - POST /worker/leases {"worker": "eu-1", "max_links": 20, "wait": "20s"}, аренда пачки ссылок одного набора.
+// Запрос ждет работу до wait, если очередь пуста - 204
+func (h *Handler) Lease(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	if !h.workerAuth(w, r) {
+		return
+	}
+
+	var body struct {
+		Worker   string `json:"worker"`
+		MaxLinks int    `json:"max_links"`
+		Wait     string `json:"wait"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		h.respondError(w, http.StatusBadRequest, "bad json")
+		return
+	}
+	wait := defaultLeaseWait
+	if body.Wait != "" {
+		d, err := time.ParseDuration(body.Wait)
+		if err != nil || d <= 0 {
+			h.respondError(w, http.StatusBadRequest, "bad wait")
+			return
+		}
+		wait = min(d, maxLeaseWait)
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), wait)
+	defer cancel()
+	lease, err := h.leases.Lease(ctx, body.Worker, body.MaxLinks)
+	switch {
+	case errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled):
+		w.WriteHeader(http.StatusNoContent)
+	case errors.Is(err, worker.ErrStopped):
+		h.respondError(w, http.StatusServiceUnavailable, err.Error())
+	case err != nil:
+		h.respondError(w, http.StatusInternalServerError, err.Error())
+	default:
+		h.respondJSON(w, http.StatusOK, lease)
+	}
+}
+
+// Heartbeat - POST /worker/leases/{lease}/heartbeat, продление аренды.
+// 404 - аренда просрочена и набор выдан снова, 410 - набор отменен, проверку нужно прекратить
+func (h *Handler) Heartbeat(w http.ResponseWriter, r *http.Request) {
+	if !h.workerAuth(w, r) {
+		return
+	}
+
+	lease, err := h.leases.Heartbeat(mux.Vars(r)["lease"])
+	if err != nil {
+		h.respondLeaseError(w, err)
+		return
+	}
+	h.respondJSON(w, http.StatusOK, lease)
+}
+
+// SubmitResults - POST /worker/leases/{lease}/results {"results": [...]}, результаты можно отправлять частями.
+// В ответе - сколько ссылок аренды еще без результата
+func (h *Handler) SubmitResults(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	if !h.workerAuth(w, r) {
+		return
+	}
+
+	var body struct {
+		Results []models.LinkResult `json:"results"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		h.respondError(w, http.StatusBadRequest, "bad json")
+		return
+	}
+
+	left, err := h.leases.Submit(mux.Vars(r)["lease"], body.Results)
+	if err != nil {
+		h.respondLeaseError(w, err)
+		return
+	}
+	h.respondJSON(w, http.StatusOK, map[string]any{"remaining": left})
+}
+
+func (h *Handler) respondLeaseError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, worker.ErrLeaseNotFound):
+		h.respondError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, worker.ErrLeaseCancelled):
+		h.respondError(w, http.StatusGone, err.Error())
+	case errors.Is(err, worker.ErrLeaseResult):
+		h.respondError(w, http.StatusBadRequest, err.Error())
+	default:
+		h.respondError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// workerAuth проверяет, что api удаленных worker включено и запрос несет токен
+func (h *Handler) workerAuth(w http.ResponseWriter, r *http.Request) bool {
+	if h.leases == nil {
+		h.respondError(w, http.StatusNotImplemented, "remote workers disabled, set LINKCHECKER_WORKER_TOKEN")
+		return false
+	}
+	if !bearer(r, h.workerToken) {
+		h.respondError(w, http.StatusUnauthorized, "bad worker token")
+		return false
+	}
+	return true
+}
+
+// bearer - запрос несет token в Authorization: Bearer
+func bearer(r *http.Request, token string) bool {
+	got := []byte(r.Header.Get("Authorization"))
+	return subtle.ConstantTimeCompare(got, []byte("Bearer "+token)) == 1
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/store"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/worker"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/models"
+)
+
+// CreateSchedule - POST /sets/{id}/schedules, тело {"interval": "5m"} или {"cron": "*/5 * * * *"}
+func (h *Handler) CreateSchedule(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	set, ok := h.loadSet(w, r)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		Interval string `json:"interval"`
+		Cron     string `json:"cron"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		h.respondError(w, http.StatusBadRequest, "bad json")
+		return
+	}
+
+	now := time.Now()
+	sc := models.Schedule{SetID: set.ID, Interval: body.Interval, Cron: body.Cron, CreatedAt: now}
+	next, err := worker.NextRun(sc, now)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	sc.NextRunAt = next
+
+	sc, err = h.store.CreateSchedule(sc)
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.respondJSON(w, http.StatusCreated, sc)
+}
+
+// ListSchedules - GET /schedules
+func (h *Handler) ListSchedules(w http.ResponseWriter, r *http.Request) {
+	schedules, err := h.store.ListSchedules()
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.respondJSON(w, http.StatusOK, map[string]any{"schedules": schedules})
+}
+
+// GetSchedule - GET /schedules/{id}, запуски можно получить через GET /sets?schedule_id={id}
+func (h *Handler) GetSchedule(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.pathID(w, r)
+	if !ok {
+		return
+	}
+
+	sc, err := h.store.GetSchedule(id)
+	if errors.Is(err, store.ErrNotFound) {
+		h.respondError(w, http.StatusNotFound, "schedule not found")
+		return
+	}
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.respondJSON(w, http.StatusOK, sc)
+}
+
+// DeleteSchedule - DELETE /schedules/{id}, уже выполненные запуски остаются
+func (h *Handler) DeleteSchedule(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.pathID(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.store.DeleteSchedule(id)
+	if errors.Is(err, store.ErrNotFound) {
+		h.respondError(w, http.StatusNotFound, "schedule not found")
+		return
+	}
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/store"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/models"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 500
+)
+
+// CreateSet - POST /sets
+func (h *Handler) CreateSet(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	var body struct {
+		Links    []string `json:"links"`
+		Async    bool     `json:"async"`
+		Priority string   `json:"priority"` //interactive, normal (по умолчанию) или bulk
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		h.respondError(w, http.StatusBadRequest, "bad json")
+		return
+	}
+
+	h.submitLinks(w, r, body.Links, body.Async, body.Priority, http.StatusCreated)
+}
+
+// GetSet - GET /sets/{id}, состояние набора ссылок
+func (h *Handler) GetSet(w http.ResponseWriter, r *http.Request) {
+	set, ok := h.loadSet(w, r)
+	if !ok {
+		return
+	}
+	h.respondJSON(w, http.StatusOK, set)
+}
+
+// ListSets - GET /sets?status=&created_after=&created_before=&limit=&offset=
+func (h *Handler) ListSets(w http.ResponseWriter, r *http.Request) {
+	q, err := parseSetQuery(r)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	sets, total, err := h.store.ListPage(q)
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, map[string]any{
+		"sets":   sets,
+		"total":  total,
+		"limit":  q.Limit,
+		"offset": q.Offset,
+	})
+}
+
+// DeleteSet - DELETE /sets/{id}
+func (h *Handler) DeleteSet(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.pathID(w, r)
+	if !ok {
+		return
+	}
+
+	if !h.checkNotSource(w, id) {
+		return
+	}
+
+	err := h.store.DeleteSet(id)
+	if errors.Is(err, store.ErrNotFound) {
+		h.respondError(w, http.StatusNotFound, "set not found")
+		return
+	}
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checkNotSource отвечает 409, если расписания берут ссылки из набора id: без набора они не смогут запускаться
+func (h *Handler) checkNotSource(w http.ResponseWriter, id int64) bool {
+	schedules, err := h.store.ListSchedules()
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, err.Error())
+		return false
+	}
+
+	var refs []int64
+	for _, sc := range schedules {
+		if sc.SetID == id {
+			refs = append(refs, sc.ID)
+		}
+	}
+	if len(refs) > 0 {
+		h.respondError(w, http.StatusConflict, fmt.Sprintf("set is the source of schedules %v, delete them first", refs))
+		return false
+	}
+	return true
+}
+
+// CancelSet - POST /sets/{id}/cancel, непроверенные ссылки помечаются cancelled, текущие запросы прерываются.
+// Повторная отмена возвращает тот же набор, завершенный набор - 409
+func (h *Handler) CancelSet(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.pathID(w, r)
+	if !ok {
+		return
+	}
+
+	set, err := h.mgr.Cancel(id)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		h.respondError(w, http.StatusNotFound, "set not found")
+	case errors.Is(err, store.ErrFinished):
+		h.respondError(w, http.StatusConflict, "set already finished")
+	case err != nil:
+		h.respondError(w, http.StatusInternalServerError, err.Error())
+	default:
+		h.respondJSON(w, http.StatusOK, set)
+	}
+}
+
+// SetReport - GET /sets/{id}/report.pdf
+func (h *Handler) SetReport(w http.ResponseWriter, r *http.Request) {
+	set, ok := h.loadSet(w, r)
+	if !ok {
+		return
+	}
+	h.respondPDF(w, []*models.LinkSet{set})
+}
+
+func (h *Handler) pathID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "bad id")
+		return 0, false
+	}
+	return id, true
+}
+
+// loadSet читает набор по id из пути, при ошибке сам отвечает клиенту
+func (h *Handler) loadSet(w http.ResponseWriter, r *http.Request) (*models.LinkSet, bool) {
+	id, ok := h.pathID(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	set, err := h.store.GetSet(id)
+	if errors.Is(err, store.ErrNotFound) || (err == nil && set == nil) {
+		h.respondError(w, http.StatusNotFound, "set not found")
+		return nil, false
+	}
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, err.Error())
+		return nil, false
+	}
+	return set, true
+}
+
+func parseSetQuery(r *http.Request) (models.SetQuery, error) {
+	v := r.URL.Query()
+	q := models.SetQuery{
+		Status: models.SetStatus(v.Get("status")),
+		Limit:  defaultPageLimit,
+	}
+	if q.Status != "" && !q.Status.IsValid() {
+		return q, errors.New("bad status")
+	}
+
+	if s := v.Get("schedule_id"); s != "" {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return q, errors.New("bad schedule_id")
+		}
+		q.ScheduleID = n
+	}
+	if s := v.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			return q, errors.New("bad limit")
+		}
+		q.Limit = min(n, maxPageLimit)
+	}
+	if s := v.Get("offset"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return q, errors.New("bad offset")
+		}
+		q.Offset = n
+	}
+	if s := v.Get("created_after"); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return q, errors.New("bad created_after, expected RFC3339")
+		}
+		q.CreatedAfter = t
+	}
+	if s := v.Get("created_before"); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return q, errors.New("bad created_before, expected RFC3339")
+		}
+		q.CreatedBefore = t
+	}
+	return q, nil
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/stats"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/models"
+)
+
+// URLStats - GET /stats?url=...&since=RFC3339, статистика по одному url за все время или с since
+func (h *Handler) URLStats(w http.ResponseWriter, r *http.Request) {
+	url := r.URL.Query().Get("url")
+	if url == "" {
+		h.respondError(w, http.StatusBadRequest, "url is required")
+		return
+	}
+	since, ok := h.parseSince(w, r)
+	if !ok {
+		return
+	}
+
+	history, err := h.store.History(url, since)
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.respondJSON(w, http.StatusOK, stats.Compute(url, history))
+}
+
+// SetStats - GET /sets/{id}/stats?since=RFC3339, статистика по каждой ссылке набора
+func (h *Handler) SetStats(w http.ResponseWriter, r *http.Request) {
+	since, ok := h.parseSince(w, r)
+	if !ok {
+		return
+	}
+	set, ok := h.loadSet(w, r)
+	if !ok {
+		return
+	}
+
+	out, err := h.linkStats([]*models.LinkSet{set}, since)
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.respondJSON(w, http.StatusOK, map[string]any{"stats": out})
+}
+
+// linkStats считает статистику по всем ссылкам наборов, каждый url один раз
+func (h *Handler) linkStats(sets []*models.LinkSet, since time.Time) ([]models.URLStats, error) {
+	seen := map[string]bool{}
+	out := []models.URLStats{}
+	for _, s := range sets {
+		for _, url := range s.Links {
+			if seen[url] {
+				continue
+			}
+			seen[url] = true
+
+			history, err := h.store.History(url, since)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, stats.Compute(url, history))
+		}
+	}
+	return out, nil
+}
+
+func (h *Handler) parseSince(w http.ResponseWriter, r *http.Request) (time.Time, bool) {
+	s := r.URL.Query().Get("since")
+	if s == "" {
+		return time.Time{}, true
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "bad since, expected RFC3339")
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// reportStats - статистика для раздела pdf отчета, ошибка не мешает построить сам отчет
+func (h *Handler) reportStats(sets []*models.LinkSet) []models.URLStats {
+	out, err := h.linkStats(sets, time.Time{})
+	if err != nil {
+		log.Printf("report stats: %v", err)
+		return nil
+	}
+	return out
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/worker"
+)
+
+// Workers - GET /admin/workers, размер и загрузка пула worker, политика автоскейлинга
+func (h *Handler) Workers(w http.ResponseWriter, r *http.Request) {
+	if h.pool == nil {
+		h.respondError(w, http.StatusNotImplemented, "worker pool management disabled")
+		return
+	}
+	h.respondJSON(w, http.StatusOK, h.workersReport())
+}
+
+// ResizeWorkers - PUT /admin/workers {"workers": n}. Пока включен автоскейлинг, размер задает он - 409
+func (h *Handler) ResizeWorkers(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	if h.pool == nil {
+		h.respondError(w, http.StatusNotImplemented, "worker pool management disabled")
+		return
+	}
+	if h.scaler != nil && h.scaler.Policy().Enabled() {
+		h.respondError(w, http.StatusConflict, "autoscaling is enabled, change LINKCHECKER_AUTOSCALE_* instead")
+		return
+	}
+
+	var body struct {
+		Workers int `json:"workers"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		h.respondError(w, http.StatusBadRequest, "bad json")
+		return
+	}
+
+	err := h.pool.Resize(body.Workers)
+	switch {
+	case errors.Is(err, worker.ErrPoolSize):
+		h.respondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, worker.ErrStopped):
+		h.respondError(w, http.StatusServiceUnavailable, err.Error())
+	case err != nil:
+		h.respondError(w, http.StatusInternalServerError, err.Error())
+	default:
+		h.respondJSON(w, http.StatusOK, h.workersReport())
+	}
+}
+
+func (h *Handler) workersReport() map[string]any {
+	s := h.pool.PoolStats()
+	out := map[string]any{
+		"workers":              s.Workers,
+		"busy":                 s.Busy,
+		"avg_check_latency_ms": s.AvgLatency.Milliseconds(),
+		"leases":               s.Leases,
+		"autoscale":            nil,
+	}
+	if h.scaler != nil {
+		if p := h.scaler.Policy(); p.Enabled() {
+			out["autoscale"] = map[string]any{
+				"min":         p.Min,
+				"max":         p.Max,
+				"interval":    p.Interval.String(),
+				"backlog":     p.Backlog,
+				"max_latency": p.MaxLatency.String(),
+			}
+		}
+	}
+	return out
+}
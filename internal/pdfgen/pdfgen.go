@@ -3,13 +3,15 @@ package pdfgen
 import (
 	"bytes"
 	"fmt"
+	"strings"
 
 	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/models"
 
 	"github.com/jung-kurt/gofpdf"
 )
 
-func GeneratePDF(sets []*models.LinkSet) ([]byte, error) {
+// stats - статистика доступности ссылок для раздела Uptime, может быть пустой
+func GeneratePDF(sets []*models.LinkSet, stats []models.URLStats) ([]byte, error) {
 	pdf := gofpdf.New("P", "mm", "A4", "") //портрет, ед. изм., формат страницы, путь к шрифтам
 
 	pdf.AddUTF8Font("DejaVu", "", "./internal/pdfgen/fonts/DejaVuSans.ttf") //для unicode символов
@@ -26,20 +28,25 @@ func GeneratePDF(sets []*models.LinkSet) ([]byte, error) {
 			res := s.Results[url]
 			st := "unknown"
 			if res != nil {
-				switch res.State {
-				case "available":
-					st = "available"
-				case "not_available":
-					st = "not available"
-				default:
-					st = string(res.State)
-				}
+				st = res.State.Label()
 			}
-			pdf.CellFormat(0, 7, fmt.Sprintf("%s - %s", url, st), "", 1, "", false, 0, "")
+			line := fmt.Sprintf("%s - %s", url, st)
+			if res != nil {
+				line += describe(res)
+			}
+			pdf.CellFormat(0, 7, line, "", 1, "", false, 0, "")
 		}
 		pdf.Ln(4)
 	}
 
+	if len(stats) > 0 {
+		pdf.Cell(0, 8, "Uptime")
+		pdf.Ln(8)
+		for _, st := range stats {
+			pdf.CellFormat(0, 7, describeStats(st), "", 1, "", false, 0, "")
+		}
+	}
+
 	buf := &bytes.Buffer{} //создаем буфер для pdf
 	err := pdf.Output(buf)
 	if err != nil {
@@ -47,3 +54,45 @@ func GeneratePDF(sets []*models.LinkSet) ([]byte, error) {
 	}
 	return buf.Bytes(), nil
 }
+
+// describe возвращает подробности проверки: код ответа, метод, время, редирект
+func describe(res *models.LinkResult) string {
+	var parts []string
+	if res.StatusCode != 0 {
+		parts = append(parts, fmt.Sprintf("%d %s", res.StatusCode, res.Method))
+	}
+	if res.State == models.StateNotAvailable && res.ErrorClass != "" {
+		parts = append(parts, res.ErrorClass)
+	}
+	if res.LatencyMs > 0 {
+		parts = append(parts, fmt.Sprintf("%d ms", res.LatencyMs))
+	}
+	if res.FinalURL != "" && strings.TrimSuffix(res.FinalURL, "/") != strings.TrimSuffix(res.URL, "/") {
+		parts = append(parts, "-> "+res.FinalURL)
+	}
+	if n := len(res.Attempts); n > 1 {
+		if res.State.IsFailure() {
+			parts = append(parts, fmt.Sprintf("failed after %d attempts", n))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d attempts", n))
+		}
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(parts, ", ") + ")"
+}
+
+// describeStats - строка раздела Uptime: доступность, задержки и последняя смена состояния
+func describeStats(st models.URLStats) string {
+	if st.Checks == 0 {
+		return fmt.Sprintf("%s - no checks yet", st.URL)
+	}
+
+	line := fmt.Sprintf("%s - uptime %.1f%% (%d checks), mean %.0f ms, p95 %d ms",
+		st.URL, st.UptimePercent, st.Checks, st.MeanLatencyMs, st.P95LatencyMs)
+	if !st.LastChangeAt.IsZero() {
+		line += fmt.Sprintf(", %s since %s", st.LastState.Label(), st.LastChangeAt.Format("2006-01-02 15:04:05"))
+	}
+	return line
+}
@@ -0,0 +1,223 @@
+package queue
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Журнал FileQueue: одна строка - одна операция с fsync. При открытии журнал
+// применяется целиком, выданные до падения наборы снова становятся ожидающими.
+// Когда лишних записей становится больше, чем наборов в очереди, журнал
+// переписывается снимком очереди через временный файл и rename.
+
+const (
+	opPush    = "push"    //набор добавлен или поднят в lane P, N - сколько раз уже выдавался (в снимке)
+	opPop     = "pop"     //набор выдан worker
+	opNack    = "nack"    //выдача отменена без попытки проверки и не засчитывается
+	opAck     = "ack"     //набор проверен и убран из очереди
+	opRequeue = "requeue" //набор проверен, но его снова поставили в очередь: в конец, счетчик выдач сначала
+
+	compactEvery = 1000
+)
+
+type record struct {
+	Op string   `json:"op"`
+	ID int64    `json:"id"`
+	P  Priority `json:"p,omitempty"` //lane для push и requeue
+	N  int      `json:"n,omitempty"`
+}
+
+type fileLog struct {
+	path    string
+	file    *os.File
+	size    int64 //длина журнала после последней успешной записи
+	records int
+	live    int //наборов в очереди при последнем снимке
+}
+
+// FileQueue - очередь с журналом на диске, переживает перезапуск сервиса
+type FileQueue struct {
+	*base
+}
+
+// OpenFileQueue открывает очередь из журнала path или создает пустую
+func OpenFileQueue(path string, opts ...Option) (*FileQueue, error) {
+	b := newBase(opts)
+
+	records, err := readLog(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("read queue %s: %v", path, err)
+	default:
+		b.fresh = false
+		b.replay(records)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	b.log = &fileLog{path: path}
+	if err := b.log.compact(b.snapshot()); err != nil { //заодно отрезает недописанную строку
+		return nil, fmt.Errorf("write queue %s: %v", path, err)
+	}
+	return &FileQueue{base: b}, nil
+}
+
+// replay восстанавливает очередь по журналу, все наборы становятся ожидающими
+func (b *base) replay(records []record) {
+	seq := map[int64]int{}
+	for i, r := range records {
+		if r.P.rank() < 0 { //журнал без lane
+			r.P = PriorityNormal
+		}
+		switch r.Op {
+		case opPush:
+			if e, ok := b.jobs[r.ID]; ok { //повторный push - только переход в lane выше
+				e.priority = r.P
+			} else {
+				b.jobs[r.ID] = &entry{priority: r.P, deliveries: r.N}
+			}
+			seq[r.ID] = i
+		case opPop:
+			if e, ok := b.jobs[r.ID]; ok {
+				e.deliveries++
+			}
+		case opNack:
+			if e, ok := b.jobs[r.ID]; ok {
+				e.deliveries = max(e.deliveries-1, 0)
+			}
+		case opRequeue:
+			if _, ok := b.jobs[r.ID]; ok {
+				b.jobs[r.ID] = &entry{priority: r.P}
+				seq[r.ID] = i
+			}
+		case opAck:
+			delete(b.jobs, r.ID)
+			delete(seq, r.ID)
+		}
+	}
+
+	ids := make([]int64, 0, len(b.jobs))
+	for id := range b.jobs {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return seq[ids[i]] < seq[ids[j]] })
+	for _, id := range ids {
+		b.enqueue(id, b.jobs[id])
+	}
+}
+
+func (l *fileLog) append(r record) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if _, err := l.file.Write(append(b, '\n')); err != nil {
+		l.file.Truncate(l.size)
+		return err
+	}
+	if err := l.file.Sync(); err != nil {
+		l.file.Truncate(l.size)
+		return err
+	}
+	l.size += int64(len(b)) + 1
+	l.records++
+	return nil
+}
+
+func (l *fileLog) needsCompact() bool {
+	return l.records >= compactEvery && l.records >= 2*l.live
+}
+
+// compact заменяет журнал снимком records
+func (l *fileLog) compact(records []record) error {
+	tmp := l.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+	var size int64
+	for _, r := range records {
+		b, _ := json.Marshal(r)
+		w.Write(append(b, '\n'))
+		size += int64(len(b)) + 1
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	f.Close()
+
+	if err := os.Rename(tmp, l.path); err != nil {
+		return err
+	}
+	if err := syncDir(filepath.Dir(l.path)); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(l.path, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if l.file != nil {
+		l.file.Close()
+	}
+	l.file = file
+	l.size = size
+	l.records = len(records)
+	l.live = len(records)
+	return nil
+}
+
+func (l *fileLog) close() error {
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// readLog читает журнал. Недописанная последняя строка (падение во время записи) отбрасывается
+func readLog(path string) ([]record, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var out []record
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var r record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			log.Printf("queue %s: skip torn record: %v", path, err)
+			break
+		}
+		out = append(out, r)
+	}
+	return out, scanner.Err()
+}
+
+// syncDir сбрасывает на диск изменения каталога (rename журнала)
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,117 @@
+package queue
+
+import "fmt"
+
+// Priority - очередь (lane), в которую попадает набор. Наборы из разных lane выдаются
+// по взвешенному round robin: на 8 интерактивных наборов приходится 4 обычных и 1 bulk,
+// пока во всех lane есть работа. Пустой lane не занимает очередь выдачи
+type Priority string
+
+const (
+	PriorityInteractive Priority = "interactive"
+	PriorityNormal      Priority = "normal"
+	PriorityBulk        Priority = "bulk"
+)
+
+// Priorities - все lane от высокого приоритета к низкому
+var Priorities = []Priority{PriorityInteractive, PriorityNormal, PriorityBulk}
+
+// DefaultWeights - доля выдач каждого lane
+var DefaultWeights = map[Priority]int{
+	PriorityInteractive: 8,
+	PriorityNormal:      4,
+	PriorityBulk:        1,
+}
+
+// ParsePriority разбирает приоритет из запроса, пустая строка - normal
+func ParsePriority(s string) (Priority, error) {
+	if s == "" {
+		return PriorityNormal, nil
+	}
+	p := Priority(s)
+	if p.rank() < 0 {
+		return "", fmt.Errorf("unknown priority %q, expected %s, %s or %s", s, PriorityInteractive, PriorityNormal, PriorityBulk)
+	}
+	return p, nil
+}
+
+// rank - позиция в Priorities, меньше - важнее; -1 для неизвестного
+func (p Priority) rank() int {
+	for i, q := range Priorities {
+		if p == q {
+			return i
+		}
+	}
+	return -1
+}
+
+// WithWeights задает доли выдач lane, lane без веса или с весом <= 0 получает 1
+func WithWeights(w map[Priority]int) Option {
+	return func(b *base) {
+		for _, l := range b.lanes {
+			l.weight = max(w[l.priority], 1)
+		}
+	}
+}
+
+type lane struct {
+	priority Priority
+	weight   int
+	current  int     //текущий вес smooth weighted round robin
+	pending  []int64 //порядок выдачи внутри lane
+}
+
+// LaneStats - глубина одного lane
+type LaneStats struct {
+	Pending  int `json:"pending"`
+	InFlight int `json:"in_flight"`
+	Weight   int `json:"weight"`
+}
+
+func newLanes() []*lane {
+	lanes := make([]*lane, len(Priorities))
+	for i, p := range Priorities {
+		lanes[i] = &lane{priority: p, weight: DefaultWeights[p]}
+	}
+	return lanes
+}
+
+// lane по приоритету, неизвестный приоритет попадает в normal
+func (b *base) lane(p Priority) *lane {
+	if r := p.rank(); r >= 0 {
+		return b.lanes[r]
+	}
+	return b.lanes[PriorityNormal.rank()]
+}
+
+// next выбирает lane для следующей выдачи (smooth weighted round robin по непустым lane), nil - ждать нечего
+func (b *base) next() *lane {
+	var (
+		best  *lane
+		total int
+	)
+	for _, l := range b.lanes {
+		if len(l.pending) == 0 {
+			continue
+		}
+		l.current += l.weight
+		total += l.weight
+		if best == nil || l.current > best.current {
+			best = l
+		}
+	}
+	if best != nil {
+		best.current -= total
+	}
+	return best
+}
+
+// remove убирает набор из ожидающих lane, вызывается под b.mu
+func (l *lane) remove(id int64) {
+	for i, v := range l.pending {
+		if v == id {
+			l.pending = append(l.pending[:i], l.pending[i+1:]...)
+			return
+		}
+	}
+}
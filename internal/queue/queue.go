@@ -0,0 +1,481 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Очередь наборов на проверку worker.
+//
+// Pop выдает набор worker и прячет его на время visibility: если worker не подтвердил
+// задачу (Ack) и не продлил ее (Touch), набор снова становится доступен другому worker.
+// Nack возвращает набор без попытки проверки (остановка сервиса) и не засчитывает выдачу,
+// Retry - после неудачной попытки, выдача засчитывается.
+// Один набор стоит в очереди не больше одного раза: повторный Push ожидающего набора
+// ничего не делает, Push выданного набора вернет его в очередь после Ack.
+// Повторный Push с более высоким приоритетом переносит набор в его lane.
+// Capacity ограничивает число наборов в очереди, при заполнении Push возвращает ErrFull.
+
+const (
+	DefaultCapacity   = 10000
+	DefaultVisibility = 5 * time.Minute
+)
+
+var (
+	ErrFull   = errors.New("queue is full")
+	ErrClosed = errors.New("queue closed")
+	ErrStale  = errors.New("job is no longer held") //истек visibility, задача выдана заново
+)
+
+// Job - выданная worker задача
+type Job struct {
+	SetID      int64
+	Priority   Priority
+	Receipt    uint64    //номер выдачи, Ack/Nack/Touch по старой выдаче возвращают ErrStale
+	Deliveries int       //сколько раз набор выдавался, включая эту выдачу
+	Deadline   time.Time //до этого времени нужно вызвать Ack, Nack или Touch
+}
+
+type Stats struct {
+	Pending  int                    `json:"pending"`
+	InFlight int                    `json:"in_flight"`
+	Capacity int                    `json:"capacity"`
+	Lanes    map[Priority]LaneStats `json:"lanes"`
+}
+
+type Queue interface {
+	Push(setID int64, p Priority) error
+	Pop(ctx context.Context) (Job, error) //ждет задачу или отмену ctx
+	Ack(Job) error                        //набор проверен, убрать из очереди
+	Nack(Job) error                       //вернуть набор в очередь сейчас, выдача не засчитывается
+	Retry(Job, time.Duration) error       //выдача не удалась: вернуть набор в очередь через d
+	Touch(Job) (Job, error)               //продлить visibility, возвращает задачу с новым Deadline
+	Stats() Stats
+	Fresh() bool //очередь создана пустой, ее нужно заполнить незавершенными наборами из store
+	Close() error
+}
+
+type Option func(*base)
+
+func WithCapacity(n int) Option {
+	return func(b *base) {
+		if n > 0 {
+			b.capacity = n
+		}
+	}
+}
+
+func WithVisibility(d time.Duration) Option {
+	return func(b *base) {
+		if d > 0 {
+			b.visibility = d
+		}
+	}
+}
+
+type entry struct {
+	priority   Priority
+	deliveries int
+	inFlight   bool
+	receipt    uint64
+	deadline   time.Time
+	again      bool //Push пришел, пока набор был выдан; priority тогда - наибольший из запрошенных
+	delayed    bool //Retry: набор ждет deadline, чтобы вернуться в очередь
+}
+
+// base - очередь в памяти, общая часть MemQueue и FileQueue. log сохраняет изменения на диск, nil - не сохранять
+type base struct {
+	mu         sync.Mutex
+	capacity   int
+	visibility time.Duration
+	lanes      []*lane //в порядке Priorities
+	jobs       map[int64]*entry
+	receipt    uint64
+	wake       chan struct{} //закрывается при каждом изменении, будит ждущие Pop
+	closed     bool
+	fresh      bool
+	log        *fileLog
+}
+
+func newBase(opts []Option) *base {
+	b := &base{
+		capacity:   DefaultCapacity,
+		visibility: DefaultVisibility,
+		lanes:      newLanes(),
+		jobs:       make(map[int64]*entry),
+		wake:       make(chan struct{}),
+		fresh:      true,
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// record пишет операцию в журнал до изменения очереди в памяти, вызывается под b.mu
+func (b *base) record(r record) error {
+	if b.log == nil {
+		return nil
+	}
+	return b.log.append(r)
+}
+
+// maybeCompact сворачивает журнал, если в нем накопилось много лишних записей; вызывается под b.mu после изменения
+func (b *base) maybeCompact() {
+	if b.log == nil || !b.log.needsCompact() {
+		return
+	}
+	if err := b.log.compact(b.snapshot()); err != nil {
+		log.Printf("queue: compact %s: %v", b.log.path, err)
+	}
+}
+
+// snapshot - текущая очередь в виде записей журнала: выданные наборы, затем ожидающие по порядку
+func (b *base) snapshot() []record {
+	out := make([]record, 0, len(b.jobs))
+	add := func(id int64) {
+		e := b.jobs[id]
+		out = append(out, record{Op: opPush, ID: id, P: e.priority, N: e.deliveries})
+	}
+
+	var held []int64
+	for id, e := range b.jobs {
+		if e.inFlight {
+			held = append(held, id)
+		}
+	}
+	sort.Slice(held, func(i, j int) bool { return held[i] < held[j] })
+
+	for _, id := range held {
+		add(id)
+	}
+	for _, l := range b.lanes {
+		for _, id := range l.pending {
+			add(id)
+		}
+	}
+	return out
+}
+
+// pending - сколько наборов ждут выдачи во всех lane, вызывается под b.mu
+func (b *base) pending() int {
+	n := 0
+	for _, l := range b.lanes {
+		n += len(l.pending)
+	}
+	return n
+}
+
+// enqueue ставит набор в конец его lane, вызывается под b.mu
+func (b *base) enqueue(id int64, e *entry) {
+	l := b.lane(e.priority)
+	l.pending = append(l.pending, id)
+}
+
+// broadcast будит ждущие Pop, вызывается под b.mu
+func (b *base) broadcast() {
+	close(b.wake)
+	b.wake = make(chan struct{})
+}
+
+func (b *base) Push(id int64, p Priority) error {
+	if p.rank() < 0 {
+		p = PriorityNormal
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return ErrClosed
+	}
+	if e, ok := b.jobs[id]; ok {
+		return b.raise(id, e, p)
+	}
+	if len(b.jobs) >= b.capacity {
+		return ErrFull
+	}
+	if err := b.record(record{Op: opPush, ID: id, P: p}); err != nil {
+		return err
+	}
+
+	e := &entry{priority: p}
+	b.jobs[id] = e
+	b.enqueue(id, e)
+	b.broadcast()
+	b.maybeCompact()
+	return nil
+}
+
+func (b *base) Pop(ctx context.Context) (Job, error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			return Job{}, err
+		}
+
+		b.mu.Lock()
+		if b.closed {
+			b.mu.Unlock()
+			return Job{}, ErrClosed
+		}
+		now := time.Now()
+		b.expire(now)
+
+		if l := b.next(); l != nil {
+			job := b.deliver(l, now)
+			b.mu.Unlock()
+			return job, nil
+		}
+
+		wake := b.wake
+		wait := time.Duration(-1)
+		if next, ok := b.nextDeadline(); ok { //задача вернется в очередь по истечении visibility
+			wait = next.Sub(now)
+		}
+		b.mu.Unlock()
+
+		b.wait(ctx, wake, wait)
+	}
+}
+
+// wait ждет изменения очереди, отмены ctx или d (d < 0 - без ограничения)
+func (b *base) wait(ctx context.Context, wake <-chan struct{}, d time.Duration) {
+	var timeout <-chan time.Time
+	if d >= 0 {
+		t := time.NewTimer(d)
+		defer t.Stop()
+		timeout = t.C
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-wake:
+	case <-timeout:
+	}
+}
+
+// deliver выдает первый ожидающий набор lane l, вызывается под b.mu
+func (b *base) deliver(l *lane, now time.Time) Job {
+	id := l.pending[0]
+	l.pending = l.pending[1:]
+
+	e := b.jobs[id]
+	b.receipt++
+	e.inFlight = true
+	e.receipt = b.receipt
+	e.deadline = now.Add(b.visibility)
+	e.deliveries++
+	if err := b.record(record{Op: opPop, ID: id}); err != nil { //теряется только счетчик выдач
+		log.Printf("queue: record delivery of set %d: %v", id, err)
+	}
+	b.maybeCompact()
+	return Job{SetID: id, Priority: e.priority, Receipt: e.receipt, Deliveries: e.deliveries, Deadline: e.deadline}
+}
+
+// expire возвращает в очередь наборы с истекшим visibility, вызывается под b.mu
+func (b *base) expire(now time.Time) {
+	var expired []int64
+	for id, e := range b.jobs {
+		if e.inFlight && now.After(e.deadline) {
+			expired = append(expired, id)
+		}
+	}
+	sort.Slice(expired, func(i, j int) bool { return expired[i] < expired[j] })
+
+	for _, id := range expired {
+		e := b.jobs[id]
+		if !e.delayed {
+			log.Printf("queue: set %d not acknowledged in %s, redelivering", id, b.visibility)
+		}
+		e.inFlight = false
+		e.again = false
+		e.delayed = false
+		b.enqueue(id, e)
+	}
+}
+
+func (b *base) nextDeadline() (time.Time, bool) {
+	var next time.Time
+	for _, e := range b.jobs {
+		if e.inFlight && (next.IsZero() || e.deadline.Before(next)) {
+			next = e.deadline
+		}
+	}
+	return next, !next.IsZero()
+}
+
+// held - запись выданной задачи, если выдача job еще действует; вызывается под b.mu
+func (b *base) held(job Job) (*entry, error) {
+	e, ok := b.jobs[job.SetID]
+	if !ok || !e.inFlight || e.receipt != job.Receipt {
+		return nil, ErrStale
+	}
+	return e, nil
+}
+
+func (b *base) Ack(job Job) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	e, err := b.held(job)
+	if err != nil {
+		return err
+	}
+	if e.again { //набор поставили в очередь повторно, пока он проверялся: в конец очереди как новый
+		if err := b.record(record{Op: opRequeue, ID: job.SetID, P: e.priority}); err != nil {
+			return err
+		}
+		next := &entry{priority: e.priority}
+		b.jobs[job.SetID] = next
+		b.enqueue(job.SetID, next)
+		b.broadcast()
+		b.maybeCompact()
+		return nil
+	}
+
+	if err := b.record(record{Op: opAck, ID: job.SetID}); err != nil {
+		return err
+	}
+	delete(b.jobs, job.SetID)
+	b.maybeCompact()
+	return nil
+}
+
+func (b *base) Nack(job Job) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	e, err := b.held(job)
+	if err != nil {
+		return err
+	}
+	if err := b.record(record{Op: opNack, ID: job.SetID}); err != nil {
+		return err
+	}
+	e.deliveries = max(e.deliveries-1, 0)
+	b.release(job.SetID, e)
+	b.maybeCompact()
+	return nil
+}
+
+func (b *base) Retry(job Job, d time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	e, err := b.held(job)
+	if err != nil {
+		return err
+	}
+	if d <= 0 {
+		b.release(job.SetID, e)
+		return nil
+	}
+	//до deadline набор остается выданным, expire вернет его в очередь
+	e.delayed = true
+	e.deadline = time.Now().Add(d)
+	b.broadcast() //ждущие Pop пересчитают ближайший deadline
+	return nil
+}
+
+// release возвращает выданный набор в конец его lane, вызывается под b.mu
+func (b *base) release(id int64, e *entry) {
+	e.inFlight = false
+	e.again = false
+	e.delayed = false
+	b.enqueue(id, e)
+	b.broadcast()
+}
+
+func (b *base) Touch(job Job) (Job, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	e, err := b.held(job)
+	if err != nil {
+		return job, err
+	}
+	e.deadline = time.Now().Add(b.visibility)
+	job.Deadline = e.deadline
+	return job, nil
+}
+
+func (b *base) Stats() Stats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.expire(time.Now())
+	st := Stats{Capacity: b.capacity, Lanes: make(map[Priority]LaneStats, len(b.lanes))}
+	for _, l := range b.lanes {
+		st.Lanes[l.priority] = LaneStats{Pending: len(l.pending), Weight: l.weight}
+	}
+	for _, e := range b.jobs {
+		if e.inFlight {
+			ls := st.Lanes[e.priority]
+			ls.InFlight++
+			st.Lanes[e.priority] = ls
+		}
+	}
+	for _, ls := range st.Lanes {
+		st.Pending += ls.Pending
+		st.InFlight += ls.InFlight
+	}
+	return st
+}
+
+// raise - Push набора, который уже в очереди: ожидающий набор переходит в lane
+// с более высоким приоритетом, выданный запоминает повторный Push. Вызывается под b.mu
+func (b *base) raise(id int64, e *entry, p Priority) error {
+	higher := p.rank() < e.priority.rank()
+	if e.inFlight {
+		if !e.again || higher {
+			e.again = true
+			if higher {
+				e.priority = p
+			}
+		}
+		return nil
+	}
+	if !higher {
+		return nil
+	}
+
+	if err := b.record(record{Op: opPush, ID: id, P: p}); err != nil {
+		return err
+	}
+	b.lane(e.priority).remove(id)
+	e.priority = p
+	b.enqueue(id, e)
+	b.broadcast()
+	b.maybeCompact()
+	return nil
+}
+
+func (b *base) Fresh() bool { return b.fresh }
+
+func (b *base) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	b.broadcast()
+	if b.log != nil {
+		return b.log.close()
+	}
+	return nil
+}
+
+// MemQueue - очередь без сохранения, после перезапуска заполняется из store заново
+type MemQueue struct {
+	*base
+}
+
+func NewMemQueue(opts ...Option) *MemQueue {
+	return &MemQueue{base: newBase(opts)}
+}
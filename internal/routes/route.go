@@ -11,7 +11,40 @@ import (
 func NewRouter(h *handlers.Handler) http.Handler {
 	r := mux.NewRouter()
 
-	r.HandleFunc("/", h.Handle).Methods("POST")
+	r.HandleFunc("/", h.Handle).Methods("POST") //старый api, тип запроса определяется по ключам json
+
+	r.HandleFunc("/sets", h.CreateSet).Methods("POST")
+	r.HandleFunc("/sets", h.ListSets).Methods("GET")
+	r.HandleFunc("/sets/{id:[0-9]+}", h.GetSet).Methods("GET")
+	r.HandleFunc("/sets/{id:[0-9]+}", h.DeleteSet).Methods("DELETE")
+	r.HandleFunc("/sets/{id:[0-9]+}/cancel", h.CancelSet).Methods("POST")
+	r.HandleFunc("/sets/{id:[0-9]+}/report.pdf", h.SetReport).Methods("GET")
+	r.HandleFunc("/sets/{id:[0-9]+}/events", h.SetEvents).Methods("GET")
+	r.HandleFunc("/sets/{id:[0-9]+}/stats", h.SetStats).Methods("GET")
+	r.HandleFunc("/sets/{id:[0-9]+}/schedules", h.CreateSchedule).Methods("POST")
+
+	r.HandleFunc("/stats", h.URLStats).Methods("GET")
+
+	r.HandleFunc("/schedules", h.ListSchedules).Methods("GET")
+	r.HandleFunc("/schedules/{id:[0-9]+}", h.GetSchedule).Methods("GET")
+	r.HandleFunc("/schedules/{id:[0-9]+}", h.DeleteSchedule).Methods("DELETE")
+
+	admin := r.PathPrefix("/admin").Subrouter() //только с токеном администратора
+	admin.Use(h.AdminAuth)
+	admin.HandleFunc("/purge", h.Purge).Methods("POST")
+	admin.HandleFunc("/export", h.Export).Methods("GET")
+	admin.HandleFunc("/import", h.Import).Methods("POST")
+	admin.HandleFunc("/queue", h.QueueStats).Methods("GET")
+	admin.HandleFunc("/workers", h.Workers).Methods("GET")
+	admin.HandleFunc("/workers", h.ResizeWorkers).Methods("PUT")
+	admin.HandleFunc("/dead-letters", h.ListDeadLetters).Methods("GET")
+	admin.HandleFunc("/dead-letters/{id:[0-9]+}", h.GetDeadLetter).Methods("GET")
+	admin.HandleFunc("/dead-letters/{id:[0-9]+}", h.DiscardDeadLetter).Methods("DELETE")
+	admin.HandleFunc("/dead-letters/{id:[0-9]+}/requeue", h.RequeueDeadLetter).Methods("POST")
+
+	r.HandleFunc("/worker/leases", h.Lease).Methods("POST") //api удаленных worker
+	r.HandleFunc("/worker/leases/{lease:[0-9a-f]+}/heartbeat", h.Heartbeat).Methods("POST")
+	r.HandleFunc("/worker/leases/{lease:[0-9a-f]+}/results", h.SubmitResults).Methods("POST")
 
 	return r
 }
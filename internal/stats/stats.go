@@ -0,0 +1,44 @@
+package stats
+
+import (
+	"sort"
+
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/models"
+)
+
+// Compute считает статистику доступности по истории проверок url (от старых к новым)
+func Compute(url string, history []models.LinkResult) models.URLStats {
+	st := models.URLStats{URL: url, Checks: len(history)}
+	if len(history) == 0 {
+		return st
+	}
+
+	var latencies []int64
+	var sum int64
+	for i, res := range history {
+		if res.State == models.StateAvailable {
+			st.Available++
+		}
+		if res.LatencyMs > 0 {
+			latencies = append(latencies, res.LatencyMs)
+			sum += res.LatencyMs
+		}
+		if i == 0 || res.State != history[i-1].State {
+			st.LastChangeAt = res.CheckedAt //с этой проверки действует текущее состояние
+		}
+	}
+
+	last := history[len(history)-1]
+	st.LastState = last.State
+	st.LastCheckedAt = last.CheckedAt
+	st.UptimePercent = float64(st.Available) * 100 / float64(st.Checks)
+
+	if len(latencies) > 0 {
+		st.MeanLatencyMs = float64(sum) / float64(len(latencies))
+
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		idx := (len(latencies)*95+99)/100 - 1 //ранг по методу nearest-rank
+		st.P95LatencyMs = latencies[idx]
+	}
+	return st
+}
@@ -0,0 +1,97 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/models"
+)
+
+// Записи dead letter FileStore: dead/<id>.json. Файл набора остается в sets/,
+// даже если он поврежден - его можно исправить и вернуть набор в очередь
+
+func (f *FileStore) deadPath(id int64) string {
+	return filepath.Join(f.dir, "dead", fmt.Sprintf("%d.json", id))
+}
+
+func (f *FileStore) DeadLetter(d models.DeadLetter) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, err := os.Stat(f.setPath(d.SetID)); errors.Is(err, os.ErrNotExist) {
+		return ErrNotFound
+	}
+
+	b, err := json.MarshalIndent(d, "", " ")
+	if err != nil {
+		return err
+	}
+	return writeFileSync(f.deadPath(d.SetID), b, 0o644)
+}
+
+func (f *FileStore) GetDeadLetter(id int64) (models.DeadLetter, error) {
+	var d models.DeadLetter
+	b, err := os.ReadFile(f.deadPath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return d, ErrNotFound
+	}
+	if err != nil {
+		return d, err
+	}
+	err = json.Unmarshal(b, &d)
+	return d, err
+}
+
+func (f *FileStore) ListDeadLetters() ([]models.DeadLetter, error) {
+	f.mu.Lock()
+	ids, err := f.deadIDs()
+	f.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]models.DeadLetter, 0, len(ids))
+	for id := range ids {
+		d, err := f.GetDeadLetter(id)
+		if errors.Is(err, ErrNotFound) { //запись убрали между ReadDir и ReadFile
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("dead letter %d: %v", id, err)
+		}
+		out = append(out, d)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].SetID < out[j].SetID })
+	return out, nil
+}
+
+func (f *FileStore) RemoveDeadLetter(id int64) error {
+	err := os.Remove(f.deadPath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// deadIDs - id наборов с записью dead letter, вызывается под f.mu
+func (f *FileStore) deadIDs() (map[int64]bool, error) {
+	files, err := os.ReadDir(filepath.Join(f.dir, "dead"))
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make(map[int64]bool, len(files))
+	for _, fi := range files {
+		id, err := strconv.ParseInt(strings.TrimSuffix(fi.Name(), ".json"), 10, 64)
+		if err == nil && strings.HasSuffix(fi.Name(), ".json") {
+			ids[id] = true
+		}
+	}
+	return ids, nil
+}
@@ -2,24 +2,59 @@ package store
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/store/migrate"
 	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/models"
 )
 
+var _ Store = (*FileStore)(nil)
+
 type FileStore struct {
-	dir  string
-	mu   sync.Mutex
-	last int64 //последний id
+	dir          string
+	mu           sync.Mutex
+	last         int64 //последний id
+	lastSchedule int64 //последний id расписания
+	notify       Notifier
+	open         map[int64]*openSet //наборы с несвернутым журналом
+	compactEvery int
 }
 
-func NewFileStore(dir string) (*FileStore, error) {
-	fs := &FileStore{dir: dir}
+type FileStoreOption func(*FileStore)
+
+// WithCompactEvery - через сколько событий журнал набора сворачивается в snapshot
+func WithCompactEvery(n int) FileStoreOption {
+	return func(f *FileStore) {
+		if n > 0 {
+			f.compactEvery = n
+		}
+	}
+}
+
+// содержимое meta.json
+type metaFile struct {
+	Last         int64 `json:"last"`
+	LastSchedule int64 `json:"last_schedule,omitempty"`
+}
+
+func NewFileStore(dir string, opts ...FileStoreOption) (*FileStore, error) {
+	fs := &FileStore{dir: dir, open: make(map[int64]*openSet), compactEvery: defaultCompactEvery}
+	for _, opt := range opts {
+		opt(fs)
+	}
 	os.MkdirAll(filepath.Join(dir, "sets"), 0o755)
+	os.MkdirAll(filepath.Join(dir, "schedules"), 0o755)
+	os.MkdirAll(filepath.Join(dir, "history"), 0o755)
+	os.MkdirAll(filepath.Join(dir, "dead"), 0o755)
 	//0o755 права доступа к папке. читать и открывать каталог всем
 	//писать только владелец
 
@@ -27,30 +62,72 @@ func NewFileStore(dir string) (*FileStore, error) {
 	meta := filepath.Join(dir, "meta.json")
 	if _, err := os.Stat(meta); err == nil {
 		b, _ := os.ReadFile(meta)
-		var m struct {
-			Last int64 `json:"last"`
-		}
+		var m metaFile
 		json.Unmarshal(b, &m)
 		fs.last = m.Last
+		fs.lastSchedule = m.LastSchedule
+	}
+
+	//обновления, которые не успели попасть в snapshot до падения
+	if err := fs.replayJournals(); err != nil {
+		return nil, err
 	}
 
 	return fs, nil
 }
 
+// SetNotifier подключает получателя обновлений (например events.Hub), вызывать до начала работы
+func (f *FileStore) SetNotifier(n Notifier) {
+	f.notify = n
+}
+
+// Close сворачивает открытые журналы в snapshot
+func (f *FileStore) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var first error
+	for id, o := range f.open {
+		if err := f.compact(o); err != nil && first == nil {
+			first = fmt.Errorf("compact set %d: %v", id, err)
+		}
+		f.closeSet(id)
+	}
+	return first
+}
+
 func (f *FileStore) persistMeta() error {
 	meta := filepath.Join(f.dir, "meta.json")
 	tmp := meta + ".tmp" //защита от повреждения файла при падении
 
-	b, _ := json.MarshalIndent(struct {
-		Last int64 `json:"last"`
-	}{f.last}, "", " ")
+	b, _ := json.MarshalIndent(metaFile{Last: f.last, LastSchedule: f.lastSchedule}, "", " ")
 
 	//0o644 - владелец может читать и запись
-	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+	if err := writeFileSync(tmp, b, 0o644); err != nil {
 		return err
 	}
 
-	return os.Rename(tmp, meta) //атомарно заменяется на meta.json
+	if err := os.Rename(tmp, meta); err != nil { //атомарно заменяется на meta.json
+		return err
+	}
+	return syncDir(f.dir)
+}
+
+// writeFileSync - os.WriteFile с fsync, чтобы после rename на диске было полное содержимое
+func writeFileSync(name string, b []byte, perm os.FileMode) error {
+	file, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(b); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
 }
 
 func (f *FileStore) nextID() (int64, error) {
@@ -71,33 +148,74 @@ func (f *FileStore) setPath(id int64) string {
 
 // безопасное созранение структуры в файл
 func (f *FileStore) saveSet(s *models.LinkSet) error {
-	p := f.setPath(s.ID)
-	tmp := p + ".tmp"
-
 	b, _ := json.MarshalIndent(s, "", " ")
+	return writeAtomic(f.setPath(s.ID), b)
+}
 
-	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+// writeAtomic заменяет файл p через временный файл с fsync и rename:
+// после падения на диске остается старое или новое содержимое целиком
+func writeAtomic(p string, b []byte) error {
+	tmp := p + ".tmp"
+	if err := writeFileSync(tmp, b, 0o644); err != nil {
 		return err
 	}
 
-	return os.Rename(tmp, p)
+	if err := os.Rename(tmp, p); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(p))
 }
 
 func (f *FileStore) GetSet(id int64) (*models.LinkSet, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if o, ok := f.open[id]; ok {
+		return o.set.Clone(), nil
+	}
+	return f.readSnapshot(id)
+}
+
+// readSnapshot читает sets/<id>.json без учета журнала. Файл старой версии схемы
+// поднимается до текущей и переписывается, вызывается под f.mu
+func (f *FileStore) readSnapshot(id int64) (*models.LinkSet, error) {
 	p := f.setPath(id)
 	b, err := os.ReadFile(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
 
+	b, changed, err := migrate.Set(b)
+	if err != nil {
+		return nil, fmt.Errorf("set %d: %v", id, err)
+	}
+
 	var s models.LinkSet
 	if err := json.Unmarshal(b, &s); err != nil {
 		return nil, err
 	}
+
+	if changed {
+		if err := f.saveSet(&s); err != nil {
+			log.Printf("set %d: save migrated: %v", id, err) //повторим при следующем чтении
+		}
+	}
 	return &s, nil
 }
 
 func (f *FileStore) CreateSet(links []string) (int64, *models.LinkSet, error) {
+	return f.createSet(links, 0)
+}
+
+// CreateScheduledSet создает набор для очередного запуска расписания scheduleID
+func (f *FileStore) CreateScheduledSet(scheduleID int64, links []string) (int64, *models.LinkSet, error) {
+	return f.createSet(links, scheduleID)
+}
+
+func (f *FileStore) createSet(links []string, scheduleID int64) (int64, *models.LinkSet, error) {
 	if len(links) == 0 {
 		return 0, nil, fmt.Errorf("нет ссылок")
 	}
@@ -108,12 +226,14 @@ func (f *FileStore) CreateSet(links []string) (int64, *models.LinkSet, error) {
 	}
 
 	s := &models.LinkSet{
-		ID:        id,
-		Links:     links,
-		Results:   make(map[string]*models.LinkResult),
-		Status:    "processing",
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+		SchemaVersion: models.SchemaVersion,
+		ID:            id,
+		Links:         links,
+		Results:       make(map[string]*models.LinkResult),
+		Status:        models.SetPending,
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
+		ScheduleID:    scheduleID,
 	}
 
 	if err := f.saveSet(s); err != nil {
@@ -123,59 +243,102 @@ func (f *FileStore) CreateSet(links []string) (int64, *models.LinkSet, error) {
 	return id, s, nil
 }
 
+// UpdateLinkResult дописывает событие в журнал набора, snapshot переписывается только при сворачивании
 func (f *FileStore) UpdateLinkResult(id int64, url string, res models.LinkResult) error {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 
-	s, err := f.GetSet(id)
-	if err != nil {
-		return err
+	o, ok := f.open[id]
+	if !ok {
+		s, err := f.readSnapshot(id)
+		if err != nil {
+			return err
+		}
+		if s.Status == models.SetCancelled {
+			return ErrCancelled
+		}
+		o = &openSet{set: s}
+		f.open[id] = o
 	}
 
-	if s.Results == nil {
-		s.Results = map[string]*models.LinkResult{}
+	//сначала журнал: если запись не удалась, набор в памяти не меняется
+	e := journalEntry{URL: url, Result: res, At: time.Now()}
+	if err := f.appendJournal(o, e); err != nil {
+		return fmt.Errorf("append journal: %v", err)
+	}
+	keep := KeepHistory(o.set.Results[url], res)
+	ApplyResult(o.set, url, res)
+	o.set.UpdatedAt = e.At
+	s := o.set.Clone()
+
+	if o.events >= f.compactEvery || s.Status.IsFinished() {
+		if err := f.compact(o); err != nil {
+			log.Printf("compact set %d: %v", id, err) //событие уже в журнале, свернется позже
+		} else if s.Status.IsFinished() {
+			f.closeSet(id)
+		}
 	}
 
-	r := res
-	s.Results[url] = &r //сохраняем результат по ключу url
-
-	allDone := true //проверка все ли ссылки обработаны
-	for _, rr := range s.Results {
-		if rr.State != models.StateAvailable &&
-			rr.State != models.StateNotAvailable {
-			allDone = false
+	if keep {
+		if err := f.appendHistory(url, res); err != nil {
+			return fmt.Errorf("append history: %v", err)
 		}
 	}
 
-	if allDone {
-		s.Status = "done"
+	if f.notify != nil {
+		f.notify.LinkUpdated(s, url, res)
+	}
+	return nil
+}
+
+// loadSet читает набор из файла, для наборов с журналом берет актуальную копию из памяти. Вызывается под f.mu
+func (f *FileStore) loadSet(name string) (*models.LinkSet, bool) {
+	id, err := strconv.ParseInt(strings.TrimSuffix(name, ".json"), 10, 64)
+	if err != nil {
+		return nil, false
+	}
+	if o, ok := f.open[id]; ok {
+		return o.set.Clone(), true
 	}
 
-	s.UpdatedAt = time.Now()
-	return f.saveSet(s)
+	s, err := f.readSnapshot(id)
+	if err != nil {
+		if !errors.Is(err, ErrNotFound) { //файл могли удалить между ReadDir и ReadFile
+			log.Printf("load set %d: %v", id, err)
+		}
+		return nil, false
+	}
+	return s, true
 }
 
 // получить все незавершенные задачи
 func (f *FileStore) ListUnfinished() ([]*models.LinkSet, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
 	dir := filepath.Join(f.dir, "sets")
 	files, err := os.ReadDir(dir)
 	if err != nil {
 		return nil, err
 	}
 
+	dead, err := f.deadIDs()
+	if err != nil {
+		return nil, err
+	}
+
 	var out []*models.LinkSet
 
 	for _, fi := range files {
-		if fi.IsDir() {
+		if fi.IsDir() || !strings.HasSuffix(fi.Name(), ".json") {
 			continue
 		}
+		if id, err := strconv.ParseInt(strings.TrimSuffix(fi.Name(), ".json"), 10, 64); err == nil && dead[id] {
+			continue //набор не удалось обработать, ждет решения через /admin/dead-letters
+		}
 
-		b, _ := os.ReadFile(filepath.Join(dir, fi.Name()))
-		var s models.LinkSet
-		if json.Unmarshal(b, &s) == nil {
-			if s.Status != "done" {
-				out = append(out, &s) //возвращаются только задачи которые надо восстановить
-			}
+		if s, ok := f.loadSet(fi.Name()); ok && !s.Status.IsFinished() {
+			out = append(out, s) //возвращаются только задачи которые надо восстановить
 		}
 	}
 
@@ -193,3 +356,132 @@ func (f *FileStore) ListSets(ids []int64) ([]*models.LinkSet, error) {
 	}
 	return out, nil
 }
+
+// ListPage возвращает наборы под фильтр q, новые первыми, и общее число подходящих
+func (f *FileStore) ListPage(q models.SetQuery) ([]*models.LinkSet, int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	dir := filepath.Join(f.dir, "sets")
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var names []string
+	for _, fi := range files {
+		if !fi.IsDir() && strings.HasSuffix(fi.Name(), ".json") {
+			names = append(names, fi.Name())
+		}
+	}
+
+	if q == (models.SetQuery{Limit: q.Limit, Offset: q.Offset}) {
+		//без фильтра читаются только наборы страницы: обходы всех наборов (store.Walk) идут страницами
+		sort.Slice(names, func(i, j int) bool { return fileID(names[i]) > fileID(names[j]) })
+		total := len(names)
+		names = names[min(q.Offset, total):]
+		if q.Limit > 0 && q.Limit < len(names) {
+			names = names[:q.Limit]
+		}
+		page := make([]*models.LinkSet, 0, len(names))
+		for _, name := range names {
+			if s, ok := f.loadSet(name); ok {
+				page = append(page, s)
+			}
+		}
+		return page, total, nil
+	}
+
+	var matched []*models.LinkSet
+	for _, name := range names {
+		if s, ok := f.loadSet(name); ok && q.Match(s) {
+			matched = append(matched, s)
+		}
+	}
+
+	sort.Slice(matched, func(i, j int) bool { return matched[i].ID > matched[j].ID })
+	return q.Page(matched), len(matched), nil
+}
+
+// fileID - id набора по имени файла, у чужих файлов 0
+func fileID(name string) int64 {
+	id, _ := strconv.ParseInt(strings.TrimSuffix(name, ".json"), 10, 64)
+	return id
+}
+
+// CancelSet отменяет набор: snapshot пишется сразу, журнал набора больше не нужен
+func (f *FileStore) CancelSet(id int64) (*models.LinkSet, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var s *models.LinkSet
+	if o, ok := f.open[id]; ok {
+		s = o.set.Clone() //кеш не меняем, пока snapshot не записан
+	} else {
+		var err error
+		if s, err = f.readSnapshot(id); err != nil {
+			return nil, err
+		}
+	}
+
+	cancelled, err := ApplyCancel(s, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if len(cancelled) > 0 { //пусто - набор уже был отменен
+		if err := f.saveSet(s); err != nil {
+			return nil, err
+		}
+		f.closeSet(id)
+		os.Remove(f.journalPath(id)) //если не удалится, replay пропустит журнал отмененного набора
+	}
+
+	if f.notify != nil {
+		for _, res := range cancelled {
+			f.notify.LinkUpdated(s.Clone(), res.URL, res)
+		}
+	}
+	return s, nil
+}
+
+// ImportSet сохраняет набор целиком, например из архива. id набора сохраняется,
+// если он больше всех выданных, иначе выдается новый - id удаленных наборов не переиспользуются
+func (f *FileStore) ImportSet(s *models.LinkSet) (int64, error) {
+	if len(s.Links) == 0 {
+		return 0, fmt.Errorf("нет ссылок")
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c := s.Clone()
+	c.SchemaVersion = models.SchemaVersion
+	if c.ID > f.last {
+		f.last = c.ID
+	} else {
+		f.last++
+		c.ID = f.last
+	}
+	if err := f.persistMeta(); err != nil {
+		return 0, fmt.Errorf("failed to get next ID: %v", err)
+	}
+
+	if err := f.saveSet(c); err != nil {
+		return 0, fmt.Errorf("failed to save set: %v", err)
+	}
+	return c.ID, nil
+}
+
+func (f *FileStore) DeleteSet(id int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.closeSet(id)
+	os.Remove(f.journalPath(id))
+	os.Remove(f.deadPath(id))
+	err := os.Remove(f.setPath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,71 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/store/migrate"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/models"
+)
+
+// Migrate поднимает все наборы в sets/ до текущей версии схемы. GetSet делает то же
+// для одного набора при чтении, Migrate нужен, чтобы обновить каталог целиком перед
+// обновлением сервиса. При dryRun файлы не меняются
+func (f *FileStore) Migrate(dryRun bool) (migrate.Report, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	report := migrate.Report{Upgraded: []int64{}}
+
+	dir := filepath.Join(f.dir, "sets")
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return report, err
+	}
+
+	for _, fi := range files {
+		name := fi.Name()
+		if fi.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		id, err := strconv.ParseInt(strings.TrimSuffix(name, ".json"), 10, 64)
+		if err != nil {
+			continue
+		}
+		report.Checked++
+
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			report.Failed = append(report.Failed, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+		b, changed, err := migrate.Set(b)
+		if err != nil {
+			report.Failed = append(report.Failed, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+		if !changed {
+			continue
+		}
+
+		if !dryRun {
+			var s models.LinkSet
+			if err := json.Unmarshal(b, &s); err != nil {
+				report.Failed = append(report.Failed, fmt.Sprintf("%s: %v", name, err))
+				continue
+			}
+			if err := f.saveSet(&s); err != nil {
+				return report, fmt.Errorf("save set %d: %v", id, err)
+			}
+		}
+		report.Upgraded = append(report.Upgraded, id)
+	}
+
+	sort.Slice(report.Upgraded, func(i, j int) bool { return report.Upgraded[i] < report.Upgraded[j] })
+	return report, nil
+}
@@ -0,0 +1,66 @@
+package store
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/models"
+)
+
+// история проверок хранится по файлу на url: history/<sha1(url)>.jsonl, одна строка - один результат
+func (f *FileStore) historyPath(url string) string {
+	sum := sha1.Sum([]byte(url))
+	return filepath.Join(f.dir, "history", hex.EncodeToString(sum[:])+".jsonl")
+}
+
+// appendHistory дописывает результат в историю url, вызывается под f.mu
+func (f *FileStore) appendHistory(url string, res models.LinkResult) error {
+	res.Attempts = nil //попытки относятся к одной проверке, в истории достаточно итога
+
+	b, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(f.historyPath(url), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(b, '\n'))
+	return err
+}
+
+// History возвращает результаты проверок url начиная с since, от старых к новым
+func (f *FileStore) History(url string, since time.Time) ([]models.LinkResult, error) {
+	file, err := os.Open(f.historyPath(url))
+	if errors.Is(err, os.ErrNotExist) {
+		return []models.LinkResult{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	out := []models.LinkResult{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var res models.LinkResult
+		if json.Unmarshal(scanner.Bytes(), &res) != nil {
+			continue //недописанная строка
+		}
+		if !since.IsZero() && res.CheckedAt.Before(since) {
+			continue
+		}
+		out = append(out, res)
+	}
+	return out, scanner.Err()
+}
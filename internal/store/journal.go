@@ -0,0 +1,179 @@
+package store
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/models"
+)
+
+// Журнал набора: sets/<id>.journal, одна строка - одно событие UpdateLinkResult.
+// Обновление дописывает строку и делает fsync вместо перезаписи всего sets/<id>.json.
+// Раз в compactEvery событий и при завершении набора журнал сворачивается в snapshot
+// sets/<id>.json и удаляется. При старте NewFileStore применяет оставшиеся журналы.
+
+const defaultCompactEvery = 100
+
+type journalEntry struct {
+	URL    string            `json:"url"`
+	Result models.LinkResult `json:"result"`
+	At     time.Time         `json:"at"` //время обновления, при replay становится UpdatedAt набора
+}
+
+// набор, у которого есть несвернутый журнал. Держится в памяти, чтобы не читать snapshot на каждое обновление
+type openSet struct {
+	set     *models.LinkSet
+	journal *os.File
+	size    int64 //длина журнала после последней успешной записи
+	events  int   //событий в журнале после последнего snapshot
+}
+
+func (f *FileStore) journalPath(id int64) string {
+	return filepath.Join(f.dir, "sets", fmt.Sprintf("%d.journal", id))
+}
+
+// appendJournal дописывает событие в журнал набора с fsync, вызывается под f.mu
+func (f *FileStore) appendJournal(o *openSet, e journalEntry) error {
+	if o.journal == nil {
+		file, err := os.OpenFile(f.journalPath(o.set.ID), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return err
+		}
+		if err := syncDir(filepath.Dir(file.Name())); err != nil { //запись о новом файле тоже должна пережить падение
+			file.Close()
+			return err
+		}
+		o.journal = file
+	}
+
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if _, err := o.journal.Write(append(b, '\n')); err != nil {
+		o.journal.Truncate(o.size) //не оставляем половину строки перед следующими событиями
+		return err
+	}
+	if err := o.journal.Sync(); err != nil {
+		o.journal.Truncate(o.size)
+		return err
+	}
+	o.size += int64(len(b)) + 1
+	o.events++
+	return nil
+}
+
+// compact записывает snapshot набора и удаляет журнал, вызывается под f.mu.
+// Если процесс упадет между записью snapshot и удалением журнала, replay применит
+// те же события повторно - результат не изменится
+func (f *FileStore) compact(o *openSet) error {
+	if err := f.saveSet(o.set); err != nil {
+		return err
+	}
+	if o.journal != nil {
+		o.journal.Close()
+		o.journal = nil
+	}
+	o.size = 0
+	if err := os.Remove(f.journalPath(o.set.ID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	o.events = 0
+	return nil
+}
+
+// closeSet закрывает журнал и убирает набор из памяти без записи snapshot, вызывается под f.mu
+func (f *FileStore) closeSet(id int64) {
+	if o, ok := f.open[id]; ok {
+		if o.journal != nil {
+			o.journal.Close()
+		}
+		delete(f.open, id)
+	}
+}
+
+// readJournal читает события журнала. Недописанная последняя строка (падение во время записи) отбрасывается
+func readJournal(path string) ([]journalEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var out []journalEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var e journalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			log.Printf("journal %s: skip torn entry: %v", path, err)
+			break
+		}
+		out = append(out, e)
+	}
+	return out, scanner.Err()
+}
+
+// replayJournals сворачивает журналы, оставшиеся после падения, в snapshot наборов
+func (f *FileStore) replayJournals() error {
+	dir := filepath.Join(f.dir, "sets")
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, fi := range files {
+		name := fi.Name()
+		if fi.IsDir() || !strings.HasSuffix(name, ".journal") {
+			continue
+		}
+		id, err := strconv.ParseInt(strings.TrimSuffix(name, ".journal"), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		s, err := f.readSnapshot(id)
+		if errors.Is(err, ErrNotFound) { //набор удален, журнал остался
+			os.Remove(filepath.Join(dir, name))
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("replay set %d: %v", id, err)
+		}
+
+		entries, err := readJournal(filepath.Join(dir, name))
+		if err != nil {
+			return fmt.Errorf("replay set %d: %v", id, err)
+		}
+		for _, e := range entries {
+			if s.Status == models.SetCancelled {
+				break //snapshot записан при отмене и уже содержит события журнала
+			}
+			ApplyResult(s, e.URL, e.Result)
+			s.UpdatedAt = e.At
+		}
+
+		if err := f.compact(&openSet{set: s}); err != nil {
+			return fmt.Errorf("replay set %d: %v", id, err)
+		}
+	}
+	return nil
+}
+
+// syncDir сбрасывает на диск изменения каталога (создание, rename, удаление файлов)
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,330 @@
+package memstore
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/store"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/models"
+)
+
+// MemStore - хранилище в памяти с той же логикой статусов, что у store.FileStore.
+// Данные теряются при перезапуске, подходит для временных развертываний и тестов
+type MemStore struct {
+	mu           sync.Mutex
+	last         int64 //последний id
+	lastSchedule int64 //последний id расписания
+	sets         map[int64]*models.LinkSet
+	schedules    map[int64]models.Schedule
+	history      map[string][]models.LinkResult
+	dead         map[int64]models.DeadLetter
+	notify       store.Notifier
+}
+
+var _ store.Store = (*MemStore)(nil)
+
+func New() *MemStore {
+	return &MemStore{
+		sets:      make(map[int64]*models.LinkSet),
+		schedules: make(map[int64]models.Schedule),
+		history:   make(map[string][]models.LinkResult),
+		dead:      make(map[int64]models.DeadLetter),
+	}
+}
+
+func (m *MemStore) SetNotifier(n store.Notifier) {
+	m.notify = n
+}
+
+func (m *MemStore) Close() error { return nil }
+
+func (m *MemStore) CreateSet(links []string) (int64, *models.LinkSet, error) {
+	return m.createSet(links, 0)
+}
+
+func (m *MemStore) CreateScheduledSet(scheduleID int64, links []string) (int64, *models.LinkSet, error) {
+	return m.createSet(links, scheduleID)
+}
+
+func (m *MemStore) createSet(links []string, scheduleID int64) (int64, *models.LinkSet, error) {
+	if len(links) == 0 {
+		return 0, nil, fmt.Errorf("нет ссылок")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.last++
+	s := &models.LinkSet{
+		SchemaVersion: models.SchemaVersion,
+		ID:            m.last,
+		Links:         append([]string(nil), links...),
+		Results:       make(map[string]*models.LinkResult),
+		Status:        models.SetPending,
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
+		ScheduleID:    scheduleID,
+	}
+	m.sets[s.ID] = s
+
+	return s.ID, s.Clone(), nil
+}
+
+func (m *MemStore) GetSet(id int64) (*models.LinkSet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.sets[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return s.Clone(), nil
+}
+
+func (m *MemStore) UpdateLinkResult(id int64, url string, res models.LinkResult) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.sets[id]
+	if !ok {
+		return store.ErrNotFound
+	}
+	if s.Status == models.SetCancelled {
+		return store.ErrCancelled
+	}
+
+	keep := store.KeepHistory(s.Results[url], res)
+	store.ApplyResult(s, url, res)
+
+	if keep {
+		h := res
+		h.Attempts = nil
+		m.history[url] = append(m.history[url], h)
+	}
+
+	if m.notify != nil {
+		m.notify.LinkUpdated(s.Clone(), url, res)
+	}
+	return nil
+}
+
+func (m *MemStore) ListSets(ids []int64) ([]*models.LinkSet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	out := make([]*models.LinkSet, 0, len(ids))
+	for _, id := range ids {
+		s, ok := m.sets[id]
+		if !ok {
+			return nil, store.ErrNotFound
+		}
+		out = append(out, s.Clone())
+	}
+	return out, nil
+}
+
+func (m *MemStore) ListPage(q models.SetQuery) ([]*models.LinkSet, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var matched []*models.LinkSet
+	for _, s := range m.sets {
+		if q.Match(s) {
+			matched = append(matched, s)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].ID > matched[j].ID })
+
+	page := q.Page(matched)
+	out := make([]*models.LinkSet, 0, len(page))
+	for _, s := range page {
+		out = append(out, s.Clone())
+	}
+	return out, len(matched), nil
+}
+
+func (m *MemStore) ListUnfinished() ([]*models.LinkSet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var out []*models.LinkSet
+	for _, s := range m.sets {
+		if _, dead := m.dead[s.ID]; !dead && !s.Status.IsFinished() {
+			out = append(out, s.Clone())
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+func (m *MemStore) CancelSet(id int64) (*models.LinkSet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.sets[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+
+	cancelled, err := store.ApplyCancel(s, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if m.notify != nil {
+		for _, res := range cancelled {
+			m.notify.LinkUpdated(s.Clone(), res.URL, res)
+		}
+	}
+	return s.Clone(), nil
+}
+
+func (m *MemStore) ImportSet(s *models.LinkSet) (int64, error) {
+	if len(s.Links) == 0 {
+		return 0, fmt.Errorf("нет ссылок")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c := s.Clone()
+	c.SchemaVersion = models.SchemaVersion
+	if c.ID > m.last {
+		m.last = c.ID
+	} else {
+		m.last++
+		c.ID = m.last
+	}
+	m.sets[c.ID] = c
+	return c.ID, nil
+}
+
+func (m *MemStore) DeleteSet(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.sets[id]; !ok {
+		return store.ErrNotFound
+	}
+	delete(m.sets, id)
+	delete(m.dead, id)
+	return nil
+}
+
+func (m *MemStore) DeadLetter(d models.DeadLetter) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.sets[d.SetID]; !ok {
+		return store.ErrNotFound
+	}
+	m.dead[d.SetID] = d
+	return nil
+}
+
+func (m *MemStore) GetDeadLetter(id int64) (models.DeadLetter, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	d, ok := m.dead[id]
+	if !ok {
+		return d, store.ErrNotFound
+	}
+	return d, nil
+}
+
+func (m *MemStore) ListDeadLetters() ([]models.DeadLetter, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	out := make([]models.DeadLetter, 0, len(m.dead))
+	for _, d := range m.dead {
+		out = append(out, d)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].SetID < out[j].SetID })
+	return out, nil
+}
+
+func (m *MemStore) RemoveDeadLetter(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.dead[id]; !ok {
+		return store.ErrNotFound
+	}
+	delete(m.dead, id)
+	return nil
+}
+
+func (m *MemStore) CreateSchedule(sc models.Schedule) (models.Schedule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.lastSchedule++
+	sc.ID = m.lastSchedule
+	if sc.CreatedAt.IsZero() {
+		sc.CreatedAt = time.Now()
+	}
+	m.schedules[sc.ID] = sc
+	return sc, nil
+}
+
+func (m *MemStore) GetSchedule(id int64) (models.Schedule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sc, ok := m.schedules[id]
+	if !ok {
+		return sc, store.ErrNotFound
+	}
+	return sc, nil
+}
+
+func (m *MemStore) UpdateSchedule(sc models.Schedule) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.schedules[sc.ID]; !ok {
+		return store.ErrNotFound //расписание удалили, пока шел запуск
+	}
+	m.schedules[sc.ID] = sc
+	return nil
+}
+
+func (m *MemStore) DeleteSchedule(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.schedules[id]; !ok {
+		return store.ErrNotFound
+	}
+	delete(m.schedules, id)
+	return nil
+}
+
+func (m *MemStore) ListSchedules() ([]models.Schedule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	out := make([]models.Schedule, 0, len(m.schedules))
+	for _, sc := range m.schedules {
+		out = append(out, sc)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+func (m *MemStore) History(url string, since time.Time) ([]models.LinkResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	out := []models.LinkResult{}
+	for _, res := range m.history[url] {
+		if !since.IsZero() && res.CheckedAt.Before(since) {
+			continue
+		}
+		out = append(out, res)
+	}
+	return out, nil
+}
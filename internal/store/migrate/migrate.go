@@ -0,0 +1,109 @@
+package migrate
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/models"
+)
+
+// Миграции работают с сырым json, а не с models.LinkSet: старый файл может не разбираться
+// текущей структурой. Каждый шаг поднимает версию на единицу, шаги применяются по порядку.
+// Новое изменение формата - новый шаг в steps и models.SchemaVersion + 1.
+
+type step struct {
+	name  string
+	apply func(doc map[string]any) error
+}
+
+// steps[i] переводит документ из версии i в i+1
+var steps = []step{
+	{"recompute set status from every link", v0ToV1},
+}
+
+func init() {
+	if len(steps) != models.SchemaVersion {
+		panic(fmt.Sprintf("migrate: %d steps for schema version %d", len(steps), models.SchemaVersion))
+	}
+}
+
+// Set поднимает json набора до models.SchemaVersion. changed - документ был старой версии
+func Set(b []byte) (out []byte, changed bool, err error) {
+	var doc map[string]any
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, false, err
+	}
+
+	v := version(doc)
+	if v > models.SchemaVersion {
+		return nil, false, fmt.Errorf("schema version %d is newer than supported %d", v, models.SchemaVersion)
+	}
+	if v == models.SchemaVersion {
+		return b, false, nil
+	}
+
+	for ; v < models.SchemaVersion; v++ {
+		if err := steps[v].apply(doc); err != nil {
+			return nil, false, fmt.Errorf("migrate %d -> %d (%s): %v", v, v+1, steps[v].name, err)
+		}
+		doc["schema_version"] = v + 1
+	}
+
+	out, err = json.MarshalIndent(doc, "", " ")
+	return out, true, err
+}
+
+// version - schema_version документа, у файлов до появления версии 0
+func version(doc map[string]any) int {
+	v, _ := doc["schema_version"].(float64)
+	return int(v)
+}
+
+// v0ToV1: до версии 1 статус набора считался только по полученным результатам,
+// и набор с непроверенными ссылками мог быть сохранен как done. Статус пересчитывается
+// по всем ссылкам, results null заменяется пустым объектом
+func v0ToV1(doc map[string]any) error {
+	links, _ := doc["links"].([]any)
+	results, _ := doc["results"].(map[string]any)
+	if results == nil {
+		results = map[string]any{}
+		doc["results"] = results
+	}
+
+	seen := map[string]bool{}
+	total, checked, terminal, failed := 0, 0, 0, 0
+	for _, l := range links {
+		url, ok := l.(string)
+		if !ok {
+			return fmt.Errorf("bad link %v", l)
+		}
+		if seen[url] {
+			continue
+		}
+		seen[url] = true
+		total++
+
+		r, ok := results[url].(map[string]any)
+		if !ok {
+			continue
+		}
+		checked++
+		state, _ := r["state"].(string)
+		if models.LinkState(state).IsTerminal() {
+			terminal++
+		}
+		if models.LinkState(state).IsFailure() {
+			failed++
+		}
+	}
+
+	doc["status"] = string(models.StatusFromCounts(total, checked, terminal, failed))
+	return nil
+}
+
+// Report - итог миграции каталога
+type Report struct {
+	Checked  int      `json:"checked"`
+	Upgraded []int64  `json:"upgraded"`
+	Failed   []string `json:"failed,omitempty"` //файлы, которые не удалось разобрать или обновить
+}
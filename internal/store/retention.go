@@ -0,0 +1,153 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/models"
+)
+
+// RetentionPolicy - какие наборы удалять. Нулевое значение поля отключает правило.
+// Незавершенные наборы и наборы, ссылки которых проверяет расписание, не удаляются никогда
+type RetentionPolicy struct {
+	MaxAge              time.Duration //удалять наборы старше
+	MaxCount            int           //хранить не больше стольких наборов, новые первыми
+	KeepLastPerSchedule int           //хранить только последние N запусков каждого расписания
+}
+
+func (p RetentionPolicy) IsZero() bool {
+	return p.MaxAge <= 0 && p.MaxCount <= 0 && p.KeepLastPerSchedule <= 0
+}
+
+// PurgeReport - что удалено за один проход
+type PurgeReport struct {
+	StartedAt time.Time `json:"started_at"`
+	DryRun    bool      `json:"dry_run"`
+	Removed   []int64   `json:"removed"`
+	Kept      int       `json:"kept"`
+}
+
+// Purge удаляет наборы по политике p. При dryRun только возвращает, что было бы удалено.
+// Последние KeepLastPerSchedule запусков расписания не удаляются по MaxAge и MaxCount,
+// чтобы у редкого расписания всегда был виден последний результат
+func Purge(st Store, p RetentionPolicy, now time.Time, dryRun bool) (PurgeReport, error) {
+	report := PurgeReport{StartedAt: now, DryRun: dryRun, Removed: []int64{}}
+
+	schedules, err := st.ListSchedules()
+	if err != nil {
+		return report, fmt.Errorf("list schedules: %v", err)
+	}
+
+	sources := make(map[int64]bool, len(schedules)) //наборы, из которых расписания берут ссылки
+	for _, sc := range schedules {
+		sources[sc.SetID] = true
+	}
+
+	//сначала обход, потом удаление: удаление во время обхода сдвигало бы страницы
+	runs := map[int64]int{} //сколько запусков расписания уже оставлено
+	err = Walk(st, func(s *models.LinkSet) error {
+		if remove(s, p, now, sources, runs, report.Kept) {
+			report.Removed = append(report.Removed, s.ID)
+		} else {
+			report.Kept++
+		}
+		return nil
+	})
+	if err != nil {
+		return report, fmt.Errorf("list sets: %v", err)
+	}
+
+	if dryRun {
+		return report, nil
+	}
+	for i, id := range report.Removed {
+		if err := st.DeleteSet(id); err != nil && !errors.Is(err, ErrNotFound) {
+			report.Removed = report.Removed[:i]
+			return report, fmt.Errorf("delete set %d: %v", id, err)
+		}
+	}
+	return report, nil
+}
+
+// remove - нужно ли удалить набор s; kept - сколько более новых наборов уже оставлено
+func remove(s *models.LinkSet, p RetentionPolicy, now time.Time, sources map[int64]bool, runs map[int64]int, kept int) bool {
+	if !s.Status.IsFinished() || sources[s.ID] {
+		return false
+	}
+
+	if s.ScheduleID != 0 && p.KeepLastPerSchedule > 0 {
+		if runs[s.ScheduleID] >= p.KeepLastPerSchedule {
+			return true
+		}
+		runs[s.ScheduleID]++
+		return false
+	}
+
+	if p.MaxAge > 0 && now.Sub(s.CreatedAt) > p.MaxAge {
+		return true
+	}
+	return p.MaxCount > 0 && kept >= p.MaxCount
+}
+
+// Janitor периодически удаляет старые наборы по RetentionPolicy
+type Janitor struct {
+	store    Store
+	policy   RetentionPolicy
+	interval time.Duration
+
+	mu       sync.Mutex //один проход за раз: фоновый и вызванный через api
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+func NewJanitor(st Store, policy RetentionPolicy, interval time.Duration) *Janitor {
+	return &Janitor{
+		store:    st,
+		policy:   policy,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+func (j *Janitor) Run() {
+	defer close(j.done)
+
+	if j.policy.IsZero() {
+		<-j.stop
+		return
+	}
+
+	t := time.NewTicker(j.interval)
+	defer t.Stop()
+
+	for {
+		if report, err := j.Purge(false); err != nil {
+			log.Printf("purge: %v", err)
+		} else if len(report.Removed) > 0 {
+			log.Printf("purge: removed %d sets", len(report.Removed))
+		}
+
+		select {
+		case <-j.stop:
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (j *Janitor) Stop() {
+	j.stopOnce.Do(func() { close(j.stop) })
+	<-j.done
+}
+
+// Purge - один проход по текущей политике
+func (j *Janitor) Purge(dryRun bool) (PurgeReport, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return Purge(j.store, j.policy, time.Now(), dryRun)
+}
@@ -0,0 +1,107 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/models"
+)
+
+func (f *FileStore) schedulePath(id int64) string {
+	return filepath.Join(f.dir, "schedules", fmt.Sprintf("%d.json", id))
+}
+
+func (f *FileStore) saveSchedule(sc models.Schedule) error {
+	b, _ := json.MarshalIndent(sc, "", " ")
+	return writeAtomic(f.schedulePath(sc.ID), b)
+}
+
+// CreateSchedule присваивает расписанию id и сохраняет его
+func (f *FileStore) CreateSchedule(sc models.Schedule) (models.Schedule, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.lastSchedule++
+	if err := f.persistMeta(); err != nil {
+		return sc, fmt.Errorf("failed to get next schedule ID: %v", err)
+	}
+
+	sc.ID = f.lastSchedule
+	if sc.CreatedAt.IsZero() {
+		sc.CreatedAt = time.Now()
+	}
+	if err := f.saveSchedule(sc); err != nil {
+		return sc, fmt.Errorf("failed to save schedule: %v", err)
+	}
+	return sc, nil
+}
+
+func (f *FileStore) GetSchedule(id int64) (models.Schedule, error) {
+	var sc models.Schedule
+
+	b, err := os.ReadFile(f.schedulePath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return sc, ErrNotFound
+	}
+	if err != nil {
+		return sc, err
+	}
+
+	err = json.Unmarshal(b, &sc)
+	return sc, err
+}
+
+func (f *FileStore) UpdateSchedule(sc models.Schedule) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, err := os.Stat(f.schedulePath(sc.ID)); errors.Is(err, os.ErrNotExist) {
+		return ErrNotFound //расписание удалили, пока шел запуск
+	}
+	return f.saveSchedule(sc)
+}
+
+func (f *FileStore) DeleteSchedule(id int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	err := os.Remove(f.schedulePath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// ListSchedules возвращает все расписания по возрастанию id
+func (f *FileStore) ListSchedules() ([]models.Schedule, error) {
+	dir := filepath.Join(f.dir, "schedules")
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	out := []models.Schedule{}
+	for _, fi := range files {
+		if fi.IsDir() || !strings.HasSuffix(fi.Name(), ".json") {
+			continue
+		}
+
+		b, err := os.ReadFile(filepath.Join(dir, fi.Name()))
+		if err != nil {
+			continue
+		}
+		var sc models.Schedule
+		if json.Unmarshal(b, &sc) == nil {
+			out = append(out, sc)
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
@@ -0,0 +1,709 @@
+package sqlitestore
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/store"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/models"
+
+	_ "modernc.org/sqlite" //чистый go драйвер, cgo не нужен
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS sets (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	links       TEXT    NOT NULL,
+	link_count  INTEGER NOT NULL,
+	status      TEXT    NOT NULL,
+	schedule_id INTEGER NOT NULL DEFAULT 0,
+	created_at  INTEGER NOT NULL,
+	updated_at  INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS sets_status ON sets(status);
+CREATE INDEX IF NOT EXISTS sets_schedule ON sets(schedule_id);
+CREATE INDEX IF NOT EXISTS sets_created ON sets(created_at);
+
+CREATE TABLE IF NOT EXISTS results (
+	set_id   INTEGER NOT NULL REFERENCES sets(id) ON DELETE CASCADE,
+	url      TEXT    NOT NULL,
+	terminal INTEGER NOT NULL,
+	data     TEXT    NOT NULL,
+	PRIMARY KEY (set_id, url)
+);
+
+CREATE TABLE IF NOT EXISTS schedules (
+	id   INTEGER PRIMARY KEY AUTOINCREMENT,
+	data TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS history (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	url        TEXT    NOT NULL,
+	checked_at INTEGER NOT NULL,
+	data       TEXT    NOT NULL
+);
+CREATE INDEX IF NOT EXISTS history_url ON history(url, checked_at);
+
+CREATE TABLE IF NOT EXISTS dead_letters (
+	set_id     INTEGER PRIMARY KEY REFERENCES sets(id) ON DELETE CASCADE,
+	reason     TEXT    NOT NULL,
+	deliveries INTEGER NOT NULL,
+	failed_at  INTEGER NOT NULL
+);
+`
+
+// SQLiteStore - хранилище в sqlite: результаты хранятся построчно,
+// обновление ссылки не переписывает весь набор
+type SQLiteStore struct {
+	db     *sql.DB
+	notify store.Notifier
+}
+
+var _ store.Store = (*SQLiteStore)(nil)
+
+func New(path string) (*SQLiteStore, error) {
+	dsn := "file:" + path + "?_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1) //sqlite допускает одного писателя, так запросы не получат SQLITE_BUSY
+
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create schema: %v", err)
+	}
+	return &SQLiteStore{db: db}, nil
+}
+
+func (s *SQLiteStore) SetNotifier(n store.Notifier) {
+	s.notify = n
+}
+
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLiteStore) CreateSet(links []string) (int64, *models.LinkSet, error) {
+	return s.createSet(links, 0)
+}
+
+func (s *SQLiteStore) CreateScheduledSet(scheduleID int64, links []string) (int64, *models.LinkSet, error) {
+	return s.createSet(links, scheduleID)
+}
+
+func (s *SQLiteStore) createSet(links []string, scheduleID int64) (int64, *models.LinkSet, error) {
+	if len(links) == 0 {
+		return 0, nil, fmt.Errorf("нет ссылок")
+	}
+
+	now := time.Now()
+	set := &models.LinkSet{
+		SchemaVersion: models.SchemaVersion,
+		Links:         links,
+		Results:       make(map[string]*models.LinkResult),
+		Status:        models.SetPending,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+		ScheduleID:    scheduleID,
+	}
+
+	b, _ := json.Marshal(links)
+	res, err := s.db.Exec(
+		`INSERT INTO sets (links, link_count, status, schedule_id, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		string(b), distinct(links), set.Status, scheduleID, now.UnixNano(), now.UnixNano())
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to save set: %v", err)
+	}
+
+	set.ID, err = res.LastInsertId()
+	if err != nil {
+		return 0, nil, err
+	}
+	return set.ID, set, nil
+}
+
+// distinct - число разных url, результаты хранятся по ключу url
+func distinct(links []string) int {
+	seen := make(map[string]struct{}, len(links))
+	for _, l := range links {
+		seen[l] = struct{}{}
+	}
+	return len(seen)
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+const setColumns = `id, links, status, schedule_id, created_at, updated_at`
+
+func scanSet(row rowScanner) (*models.LinkSet, error) {
+	var (
+		set              models.LinkSet
+		links            string
+		created, updated int64
+	)
+	if err := row.Scan(&set.ID, &links, &set.Status, &set.ScheduleID, &created, &updated); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(links), &set.Links); err != nil {
+		return nil, fmt.Errorf("set %d: bad links: %v", set.ID, err)
+	}
+	set.CreatedAt = time.Unix(0, created)
+	set.UpdatedAt = time.Unix(0, updated)
+	set.Results = make(map[string]*models.LinkResult)
+	set.SchemaVersion = models.SchemaVersion //формат строк задает схема таблиц, json набора не хранится
+	return &set, nil
+}
+
+// resultsBatch - сколько id наборов в одном запросе loadResults, sqlite ограничивает число параметров запроса
+const resultsBatch = 500
+
+// loadResults заполняет Results для наборов, по запросу на resultsBatch наборов
+func (s *SQLiteStore) loadResults(sets []*models.LinkSet) error {
+	for len(sets) > 0 {
+		n := min(len(sets), resultsBatch)
+		if err := s.loadBatch(sets[:n]); err != nil {
+			return err
+		}
+		sets = sets[n:]
+	}
+	return nil
+}
+
+func (s *SQLiteStore) loadBatch(sets []*models.LinkSet) error {
+	byID := make(map[int64]*models.LinkSet, len(sets))
+	args := make([]any, 0, len(sets))
+	for _, set := range sets {
+		byID[set.ID] = set
+		args = append(args, set.ID)
+	}
+
+	rows, err := s.db.Query(
+		`SELECT set_id, url, data FROM results WHERE set_id IN (`+placeholders(len(args))+`)`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id       int64
+			url, raw string
+		)
+		if err := rows.Scan(&id, &url, &raw); err != nil {
+			return err
+		}
+		var res models.LinkResult
+		if err := json.Unmarshal([]byte(raw), &res); err != nil {
+			return fmt.Errorf("set %d: bad result %s: %v", id, url, err)
+		}
+		byID[id].Results[url] = &res
+	}
+	return rows.Err()
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
+
+func (s *SQLiteStore) GetSet(id int64) (*models.LinkSet, error) {
+	set, err := scanSet(s.db.QueryRow(`SELECT `+setColumns+` FROM sets WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.loadResults([]*models.LinkSet{set}); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+func (s *SQLiteStore) UpdateLinkResult(id int64, url string, res models.LinkResult) error {
+	b, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var (
+		status     models.SetStatus
+		scheduleID int64
+		linkCount  int
+	)
+	err = tx.QueryRow(`SELECT status, schedule_id, link_count FROM sets WHERE id = ?`, id).Scan(&status, &scheduleID, &linkCount)
+	if errors.Is(err, sql.ErrNoRows) {
+		return store.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if status == models.SetCancelled {
+		return store.ErrCancelled
+	}
+
+	var prev *models.LinkResult
+	var prevData string
+	err = tx.QueryRow(`SELECT data FROM results WHERE set_id = ? AND url = ?`, id, url).Scan(&prevData)
+	switch {
+	case err == nil:
+		prev = &models.LinkResult{}
+		if err := json.Unmarshal([]byte(prevData), prev); err != nil {
+			return err
+		}
+	case !errors.Is(err, sql.ErrNoRows):
+		return err
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO results (set_id, url, terminal, data) VALUES (?, ?, ?, ?)
+		 ON CONFLICT (set_id, url) DO UPDATE SET terminal = excluded.terminal, data = excluded.data`,
+		id, url, res.State.IsTerminal(), string(b))
+	if err != nil {
+		return err
+	}
+
+	//статус считается по всем ссылкам набора: link_count против результатов в results
+	var checked, terminal, failed int
+	err = tx.QueryRow(
+		`SELECT COUNT(*), COALESCE(SUM(terminal), 0),
+		        COALESCE(SUM(terminal = 1 AND json_extract(data, '$.state') != ?), 0)
+		 FROM results WHERE set_id = ?`, models.StateAvailable, id).Scan(&checked, &terminal, &failed)
+	if err != nil {
+		return err
+	}
+	status = models.StatusFromCounts(linkCount, checked, terminal, failed)
+
+	now := time.Now()
+	if _, err := tx.Exec(`UPDATE sets SET status = ?, updated_at = ? WHERE id = ?`, status, now.UnixNano(), id); err != nil {
+		return err
+	}
+
+	if store.KeepHistory(prev, res) {
+		h := res
+		h.Attempts = nil
+		hb, _ := json.Marshal(h)
+		if _, err := tx.Exec(`INSERT INTO history (url, checked_at, data) VALUES (?, ?, ?)`,
+			url, res.CheckedAt.UnixNano(), string(hb)); err != nil {
+			return fmt.Errorf("append history: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	if s.notify != nil {
+		s.notify.LinkUpdated(&models.LinkSet{ID: id, Status: status, UpdatedAt: now, ScheduleID: scheduleID}, url, res)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) ListSets(ids []int64) ([]*models.LinkSet, error) {
+	out := make([]*models.LinkSet, 0, len(ids))
+	for _, id := range ids {
+		set, err := s.GetSet(id)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, set)
+	}
+	return out, nil
+}
+
+func (s *SQLiteStore) querySets(query string, args ...any) ([]*models.LinkSet, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []*models.LinkSet
+	for rows.Next() {
+		set, err := scanSet(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		out = append(out, set)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := s.loadResults(out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (s *SQLiteStore) ListPage(q models.SetQuery) ([]*models.LinkSet, int, error) {
+	var (
+		where []string
+		args  []any
+	)
+	if q.Status != "" {
+		where = append(where, "status = ?")
+		args = append(args, q.Status)
+	}
+	if q.ScheduleID != 0 {
+		where = append(where, "schedule_id = ?")
+		args = append(args, q.ScheduleID)
+	}
+	if !q.CreatedAfter.IsZero() {
+		where = append(where, "created_at > ?")
+		args = append(args, q.CreatedAfter.UnixNano())
+	}
+	if !q.CreatedBefore.IsZero() {
+		where = append(where, "created_at < ?")
+		args = append(args, q.CreatedBefore.UnixNano())
+	}
+
+	cond := ""
+	if len(where) > 0 {
+		cond = " WHERE " + strings.Join(where, " AND ")
+	}
+
+	var total int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM sets`+cond, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = -1 //в sqlite LIMIT -1 - без ограничения
+	}
+	sets, err := s.querySets(`SELECT `+setColumns+` FROM sets`+cond+` ORDER BY id DESC LIMIT ? OFFSET ?`,
+		append(args, limit, q.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	if sets == nil {
+		sets = []*models.LinkSet{}
+	}
+	return sets, total, nil
+}
+
+func (s *SQLiteStore) ListUnfinished() ([]*models.LinkSet, error) {
+	return s.querySets(`SELECT `+setColumns+` FROM sets
+		WHERE status IN (?, ?) AND id NOT IN (SELECT set_id FROM dead_letters) ORDER BY id`,
+		models.SetPending, models.SetProcessing)
+}
+
+// CancelSet отменяет набор в одной транзакции с чтением его результатов
+func (s *SQLiteStore) CancelSet(id int64) (*models.LinkSet, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	//внутри транзакции только tx: у пула одно соединение, запрос через s.db будет ждать вечно
+	set, err := scanSet(tx.QueryRow(`SELECT `+setColumns+` FROM sets WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query(`SELECT url, data FROM results WHERE set_id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var url, data string
+		if err := rows.Scan(&url, &data); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		var r models.LinkResult
+		if err := json.Unmarshal([]byte(data), &r); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("set %d: bad result %s: %v", id, url, err)
+		}
+		set.Results[url] = &r
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	cancelled, err := store.ApplyCancel(set, now)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range cancelled {
+		b, _ := json.Marshal(r)
+		_, err := tx.Exec(
+			`INSERT INTO results (set_id, url, terminal, data) VALUES (?, ?, 0, ?)
+			 ON CONFLICT (set_id, url) DO UPDATE SET terminal = 0, data = excluded.data`,
+			id, r.URL, string(b))
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(cancelled) > 0 { //пусто - набор уже был отменен
+		if _, err := tx.Exec(`UPDATE sets SET status = ?, updated_at = ? WHERE id = ?`, set.Status, now.UnixNano(), id); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	if s.notify != nil {
+		for _, r := range cancelled {
+			s.notify.LinkUpdated(set.Clone(), r.URL, r)
+		}
+	}
+	return set, nil
+}
+
+// ImportSet сохраняет набор вместе с результатами. id сохраняется, если он больше всех выданных
+func (s *SQLiteStore) ImportSet(set *models.LinkSet) (int64, error) {
+	if len(set.Links) == 0 {
+		return 0, fmt.Errorf("нет ссылок")
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var last int64 //sqlite_sequence хранит последний выданный id, в том числе удаленных наборов
+	if err := tx.QueryRow(`SELECT COALESCE(MAX(seq), 0) FROM sqlite_sequence WHERE name = 'sets'`).Scan(&last); err != nil {
+		return 0, err
+	}
+
+	var id any //nil - id выдаст sqlite
+	if set.ID > last {
+		id = set.ID
+	}
+
+	b, _ := json.Marshal(set.Links)
+	res, err := tx.Exec(
+		`INSERT INTO sets (id, links, link_count, status, schedule_id, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		id, string(b), distinct(set.Links), set.Status, set.ScheduleID, set.CreatedAt.UnixNano(), set.UpdatedAt.UnixNano())
+	if err != nil {
+		return 0, fmt.Errorf("failed to save set: %v", err)
+	}
+	newID, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	for url, r := range set.Results {
+		rb, _ := json.Marshal(r)
+		if _, err := tx.Exec(`INSERT INTO results (set_id, url, terminal, data) VALUES (?, ?, ?, ?)`,
+			newID, url, r.State.IsTerminal(), string(rb)); err != nil {
+			return 0, err
+		}
+	}
+	return newID, tx.Commit()
+}
+
+func (s *SQLiteStore) DeleteSet(id int64) error {
+	res, err := s.db.Exec(`DELETE FROM sets WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func (s *SQLiteStore) DeadLetter(d models.DeadLetter) error {
+	res, err := s.db.Exec(
+		`INSERT INTO dead_letters (set_id, reason, deliveries, failed_at) SELECT id, ?, ?, ? FROM sets WHERE id = ?
+		 ON CONFLICT (set_id) DO UPDATE SET reason = excluded.reason, deliveries = excluded.deliveries, failed_at = excluded.failed_at`,
+		d.Reason, d.Deliveries, d.FailedAt.UnixNano(), d.SetID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 { //набора нет, SELECT ничего не вернул
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func scanDeadLetter(row rowScanner) (models.DeadLetter, error) {
+	var (
+		d      models.DeadLetter
+		failed int64
+	)
+	if err := row.Scan(&d.SetID, &d.Reason, &d.Deliveries, &failed); err != nil {
+		return d, err
+	}
+	d.FailedAt = time.Unix(0, failed)
+	return d, nil
+}
+
+func (s *SQLiteStore) GetDeadLetter(id int64) (models.DeadLetter, error) {
+	d, err := scanDeadLetter(s.db.QueryRow(`SELECT set_id, reason, deliveries, failed_at FROM dead_letters WHERE set_id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return d, store.ErrNotFound
+	}
+	return d, err
+}
+
+func (s *SQLiteStore) ListDeadLetters() ([]models.DeadLetter, error) {
+	rows, err := s.db.Query(`SELECT set_id, reason, deliveries, failed_at FROM dead_letters ORDER BY set_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []models.DeadLetter{}
+	for rows.Next() {
+		d, err := scanDeadLetter(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+func (s *SQLiteStore) RemoveDeadLetter(id int64) error {
+	res, err := s.db.Exec(`DELETE FROM dead_letters WHERE set_id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func (s *SQLiteStore) CreateSchedule(sc models.Schedule) (models.Schedule, error) {
+	if sc.CreatedAt.IsZero() {
+		sc.CreatedAt = time.Now()
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return sc, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`INSERT INTO schedules (data) VALUES ('{}')`)
+	if err != nil {
+		return sc, fmt.Errorf("failed to save schedule: %v", err)
+	}
+	if sc.ID, err = res.LastInsertId(); err != nil {
+		return sc, err
+	}
+
+	b, _ := json.Marshal(sc)
+	if _, err := tx.Exec(`UPDATE schedules SET data = ? WHERE id = ?`, string(b), sc.ID); err != nil {
+		return sc, fmt.Errorf("failed to save schedule: %v", err)
+	}
+	return sc, tx.Commit()
+}
+
+func (s *SQLiteStore) GetSchedule(id int64) (models.Schedule, error) {
+	var (
+		sc  models.Schedule
+		raw string
+	)
+	err := s.db.QueryRow(`SELECT data FROM schedules WHERE id = ?`, id).Scan(&raw)
+	if errors.Is(err, sql.ErrNoRows) {
+		return sc, store.ErrNotFound
+	}
+	if err != nil {
+		return sc, err
+	}
+
+	err = json.Unmarshal([]byte(raw), &sc)
+	return sc, err
+}
+
+func (s *SQLiteStore) UpdateSchedule(sc models.Schedule) error {
+	b, _ := json.Marshal(sc)
+	res, err := s.db.Exec(`UPDATE schedules SET data = ? WHERE id = ?`, string(b), sc.ID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return store.ErrNotFound //расписание удалили, пока шел запуск
+	}
+	return nil
+}
+
+func (s *SQLiteStore) DeleteSchedule(id int64) error {
+	res, err := s.db.Exec(`DELETE FROM schedules WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func (s *SQLiteStore) ListSchedules() ([]models.Schedule, error) {
+	rows, err := s.db.Query(`SELECT data FROM schedules ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []models.Schedule{}
+	for rows.Next() {
+		var raw string
+		if err := rows.Scan(&raw); err != nil {
+			return nil, err
+		}
+		var sc models.Schedule
+		if json.Unmarshal([]byte(raw), &sc) == nil {
+			out = append(out, sc)
+		}
+	}
+	return out, rows.Err()
+}
+
+func (s *SQLiteStore) History(url string, since time.Time) ([]models.LinkResult, error) {
+	var from int64 = math.MinInt64 //нулевое время - вся история
+	if !since.IsZero() {
+		from = since.UnixNano()
+	}
+
+	rows, err := s.db.Query(`SELECT data FROM history WHERE url = ? AND checked_at >= ? ORDER BY checked_at, id`, url, from)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []models.LinkResult{}
+	for rows.Next() {
+		var raw string
+		if err := rows.Scan(&raw); err != nil {
+			return nil, err
+		}
+		var res models.LinkResult
+		if json.Unmarshal([]byte(raw), &res) == nil {
+			out = append(out, res)
+		}
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,137 @@
+package store
+
+import (
+	"errors"
+	"time"
+
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/models"
+)
+
+var (
+	ErrNotFound  = errors.New("not found")
+	ErrCancelled = errors.New("set cancelled")        //результат пришел после отмены набора и не сохранен
+	ErrFinished  = errors.New("set already finished") //отменять нечего, все ссылки проверены
+)
+
+// Store - общий интерфейс хранилищ наборов ссылок, им пользуются обработчики, worker и планировщик
+type Store interface {
+	CreateSet([]string) (int64, *models.LinkSet, error)
+	CreateScheduledSet(int64, []string) (int64, *models.LinkSet, error)
+	GetSet(int64) (*models.LinkSet, error)
+	UpdateLinkResult(int64, string, models.LinkResult) error
+	ListSets([]int64) ([]*models.LinkSet, error)
+	ListPage(models.SetQuery) ([]*models.LinkSet, int, error)
+	ListUnfinished() ([]*models.LinkSet, error)
+	DeleteSet(int64) error
+	ImportSet(*models.LinkSet) (int64, error)
+	CancelSet(int64) (*models.LinkSet, error)
+
+	//dead letter: набор исключается из ListUnfinished, сам набор остается на месте.
+	//DeleteSet удаляет и запись dead letter
+	DeadLetter(models.DeadLetter) error //ErrNotFound, если набора нет
+	GetDeadLetter(int64) (models.DeadLetter, error)
+	ListDeadLetters() ([]models.DeadLetter, error) //по возрастанию id набора
+	RemoveDeadLetter(int64) error                  //вернуть набор в ListUnfinished
+
+	CreateSchedule(models.Schedule) (models.Schedule, error)
+	GetSchedule(int64) (models.Schedule, error)
+	UpdateSchedule(models.Schedule) error
+	DeleteSchedule(int64) error
+	ListSchedules() ([]models.Schedule, error)
+
+	History(string, time.Time) ([]models.LinkResult, error)
+
+	SetNotifier(Notifier)
+	Close() error
+}
+
+// Notifier получает уведомление о каждом сохраненном результате ссылки.
+// set - набор после обновления, хранилище может не заполнять в нем Links и Results
+type Notifier interface {
+	LinkUpdated(set *models.LinkSet, url string, res models.LinkResult)
+}
+
+// PageSize - по сколько наборов читают хранилище обходы всех наборов (очистка, экспорт)
+const PageSize = 500
+
+// Walk обходит все наборы страницами по PageSize, новые первыми, и не держит в памяти больше страницы.
+// Наборы, сдвинутые на следующую страницу новыми наборами, повторно не передаются
+func Walk(st Store, fn func(*models.LinkSet) error) error {
+	seen := map[int64]bool{}
+	for offset := 0; ; offset += PageSize {
+		sets, _, err := st.ListPage(models.SetQuery{Limit: PageSize, Offset: offset})
+		if err != nil {
+			return err
+		}
+		for _, s := range sets {
+			if seen[s.ID] {
+				continue
+			}
+			seen[s.ID] = true
+			if err := fn(s); err != nil {
+				return err
+			}
+		}
+		if len(sets) < PageSize {
+			return nil
+		}
+	}
+}
+
+// ApplyResult записывает результат ссылки в набор и пересчитывает статус набора.
+// Общая логика для хранилищ, которые держат набор целиком (FileStore, memstore)
+func ApplyResult(s *models.LinkSet, url string, res models.LinkResult) {
+	if s.Results == nil {
+		s.Results = map[string]*models.LinkResult{}
+	}
+
+	r := res
+	s.Results[url] = &r //сохраняем результат по ключу url
+
+	s.Status = s.DeriveStatus() //по всем ссылкам набора, а не только по Results
+
+	s.UpdatedAt = time.Now()
+}
+
+// KeepHistory - попадает ли res в историю ссылки. В историю пишется только итоговый результат проверки:
+// processing и промежуточные попытки пропускаются, повторная запись того же результата
+// (prev завершен с тем же временем проверки) историю не дополняет
+func KeepHistory(prev *models.LinkResult, res models.LinkResult) bool {
+	if !res.State.IsTerminal() {
+		return false
+	}
+	return prev == nil || !prev.State.IsTerminal() || !prev.CheckedAt.Equal(res.CheckedAt)
+}
+
+// ApplyCancel переводит набор в cancelled, ссылки без завершенного результата помечаются отмененными.
+// Возвращает результаты этих ссылок. Повторная отмена ничего не меняет, завершенный набор - ErrFinished
+func ApplyCancel(s *models.LinkSet, now time.Time) ([]models.LinkResult, error) {
+	switch {
+	case s.Status == models.SetCancelled:
+		return nil, nil
+	case s.Status.IsFinished():
+		return nil, ErrFinished
+	}
+	if s.Results == nil {
+		s.Results = map[string]*models.LinkResult{}
+	}
+
+	var out []models.LinkResult
+	for _, url := range s.Links {
+		prev := s.Results[url]
+		if prev != nil && (prev.State.IsTerminal() || prev.State == models.StateCancelled) {
+			continue //проверенные ссылки и повторы в Links
+		}
+
+		r := models.LinkResult{URL: url, State: models.StateCancelled, CheckedAt: now, Detail: "cancelled"}
+		if prev != nil {
+			r.Attempts = prev.Attempts //попытки до отмены остаются
+		}
+		s.Results[url] = &r
+		out = append(out, r)
+	}
+
+	s.Status = models.SetCancelled
+	s.UpdatedAt = now
+	return out, nil
+}
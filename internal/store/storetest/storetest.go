@@ -0,0 +1,721 @@
+// Package storetest - набор проверок, которые должна проходить любая реализация store.Store
+package storetest
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/store"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/models"
+)
+
+// Backend описывает тестируемое хранилище
+type Backend struct {
+	// Open открывает хранилище в каталоге dir. Повторный вызов с тем же dir имитирует перезапуск сервиса
+	Open func(t *testing.T, dir string) store.Store
+	// Persistent - данные переживают повторный Open, иначе проверки восстановления пропускаются
+	Persistent bool
+}
+
+// Run запускает все проверки для backend, каждая проверка получает чистый каталог
+func Run(t *testing.T, b Backend) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, b Backend, dir string)
+	}{
+		{"CreateAndGet", testCreateAndGet},
+		{"NotFound", testNotFound},
+		{"UpdateLinkResult", testUpdateLinkResult},
+		{"StatusLifecycle", testStatusLifecycle},
+		{"UncheckedLinksNotDone", testUncheckedLinksNotDone},
+		{"ConcurrentUpdates", testConcurrentUpdates},
+		{"ListUnfinished", testListUnfinished},
+		{"ListPage", testListPage},
+		{"Walk", testWalk},
+		{"DeleteSet", testDeleteSet},
+		{"ImportSet", testImportSet},
+		{"CancelSet", testCancelSet},
+		{"DeadLetter", testDeadLetter},
+		{"Schedules", testSchedules},
+		{"History", testHistory},
+		{"Notifier", testNotifier},
+		{"IDMonotonicity", testIDMonotonicity},
+		{"RestartRecovery", testRestartRecovery},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.fn(t, b, t.TempDir())
+		})
+	}
+}
+
+func open(t *testing.T, b Backend, dir string) store.Store {
+	t.Helper()
+	st := b.Open(t, dir)
+	t.Cleanup(func() { st.Close() })
+	return st
+}
+
+func result(url string, state models.LinkState) models.LinkResult {
+	return models.LinkResult{URL: url, State: state, CheckedAt: time.Now(), LatencyMs: 42}
+}
+
+func mustCreate(t *testing.T, st store.Store, links ...string) int64 {
+	t.Helper()
+	id, _, err := st.CreateSet(links)
+	if err != nil {
+		t.Fatalf("create set: %v", err)
+	}
+	return id
+}
+
+func mustUpdate(t *testing.T, st store.Store, id int64, res models.LinkResult) {
+	t.Helper()
+	if err := st.UpdateLinkResult(id, res.URL, res); err != nil {
+		t.Fatalf("update %s: %v", res.URL, err)
+	}
+}
+
+func mustGet(t *testing.T, st store.Store, id int64) *models.LinkSet {
+	t.Helper()
+	s, err := st.GetSet(id)
+	if err != nil {
+		t.Fatalf("get set %d: %v", id, err)
+	}
+	return s
+}
+
+func testCreateAndGet(t *testing.T, b Backend, dir string) {
+	st := open(t, b, dir)
+
+	before := time.Now()
+	id, created, err := st.CreateSet([]string{"a.com", "b.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created == nil || created.ID != id {
+		t.Fatalf("CreateSet must return the set with its id, got %+v", created)
+	}
+
+	s := mustGet(t, st, id)
+	if len(s.Links) != 2 || s.Links[0] != "a.com" || s.Links[1] != "b.com" {
+		t.Errorf("links not preserved: %v", s.Links)
+	}
+	if s.Status != models.SetPending {
+		t.Errorf("new set status: expected pending, got %s", s.Status)
+	}
+	if len(s.Results) != 0 {
+		t.Errorf("new set must have no results, got %v", s.Results)
+	}
+	if s.CreatedAt.Before(before.Add(-time.Second)) || s.UpdatedAt.Before(s.CreatedAt.Add(-time.Millisecond)) {
+		t.Errorf("bad timestamps: created %v, updated %v", s.CreatedAt, s.UpdatedAt)
+	}
+
+	if _, _, err := st.CreateSet(nil); err == nil {
+		t.Error("CreateSet without links must fail")
+	}
+
+	sid, _, err := st.CreateScheduledSet(5, []string{"c.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := mustGet(t, st, sid); s.ScheduleID != 5 {
+		t.Errorf("scheduled set: expected schedule_id 5, got %d", s.ScheduleID)
+	}
+}
+
+func testNotFound(t *testing.T, b Backend, dir string) {
+	st := open(t, b, dir)
+
+	if _, err := st.GetSet(404); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetSet: expected ErrNotFound, got %v", err)
+	}
+	if err := st.DeleteSet(404); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("DeleteSet: expected ErrNotFound, got %v", err)
+	}
+	if _, err := st.ListSets([]int64{404}); err == nil {
+		t.Error("ListSets with unknown id must fail")
+	}
+	if _, err := st.GetSchedule(404); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetSchedule: expected ErrNotFound, got %v", err)
+	}
+	if err := st.DeleteSchedule(404); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("DeleteSchedule: expected ErrNotFound, got %v", err)
+	}
+}
+
+func testUpdateLinkResult(t *testing.T, b Backend, dir string) {
+	st := open(t, b, dir)
+	id := mustCreate(t, st, "a.com", "b.com")
+	created := mustGet(t, st, id).UpdatedAt
+
+	time.Sleep(2 * time.Millisecond)
+	res := result("a.com", models.StateServerError)
+	res.StatusCode = 503
+	res.Attempts = []models.LinkAttempt{{State: models.StateTimeout}, {State: models.StateServerError, StatusCode: 503}}
+	mustUpdate(t, st, id, res)
+
+	s := mustGet(t, st, id)
+	got := s.Results["a.com"]
+	if got == nil || got.State != models.StateServerError || got.StatusCode != 503 || len(got.Attempts) != 2 {
+		t.Fatalf("result not stored: %+v", got)
+	}
+	if !s.UpdatedAt.After(created) {
+		t.Errorf("UpdatedAt must advance: created %v, updated %v", created, s.UpdatedAt)
+	}
+
+	//изменение полученной копии не должно попадать в хранилище
+	s.Results["a.com"].State = models.StateAvailable
+	s.Links[0] = "changed"
+	if again := mustGet(t, st, id); again.Results["a.com"].State != models.StateServerError || again.Links[0] != "a.com" {
+		t.Error("store must not share internal state with callers")
+	}
+
+	if err := st.UpdateLinkResult(404, "a.com", res); err == nil {
+		t.Error("UpdateLinkResult for unknown set must fail")
+	}
+}
+
+func testStatusLifecycle(t *testing.T, b Backend, dir string) {
+	st := open(t, b, dir)
+	id := mustCreate(t, st, "a.com", "b.com")
+
+	mustUpdate(t, st, id, result("a.com", models.StateProcessing))
+	mustUpdate(t, st, id, result("b.com", models.StateAvailable))
+	if s := mustGet(t, st, id); s.Status != models.SetProcessing {
+		t.Fatalf("set with a processing link: expected processing, got %s", s.Status)
+	}
+
+	mustUpdate(t, st, id, result("a.com", models.StateDNSError))
+	if s := mustGet(t, st, id); s.Status != models.SetPartiallyFailed {
+		t.Fatalf("all links checked, one failed: expected partially_failed, got %s", s.Status)
+	}
+
+	//повторная проверка вернула ссылку в работу
+	mustUpdate(t, st, id, result("a.com", models.StateProcessing))
+	if s := mustGet(t, st, id); s.Status != models.SetProcessing {
+		t.Fatalf("recheck: expected processing, got %s", s.Status)
+	}
+
+	mustUpdate(t, st, id, result("a.com", models.StateAvailable))
+	if s := mustGet(t, st, id); s.Status != models.SetDone {
+		t.Fatalf("every link available: expected done, got %s", s.Status)
+	}
+}
+
+// регрессия: статус считался только по Results, и набор становился done после первого результата
+func testUncheckedLinksNotDone(t *testing.T, b Backend, dir string) {
+	st := open(t, b, dir)
+	id := mustCreate(t, st, "a.com", "b.com", "c.com")
+
+	mustUpdate(t, st, id, result("a.com", models.StateAvailable))
+	if s := mustGet(t, st, id); s.Status != models.SetProcessing {
+		t.Fatalf("links without results: expected processing, got %s", s.Status)
+	}
+
+	unfinished, err := st.ListUnfinished()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unfinished) != 1 || unfinished[0].ID != id {
+		t.Errorf("set with unchecked links must be listed as unfinished, got %v", unfinished)
+	}
+}
+
+func testConcurrentUpdates(t *testing.T, b Backend, dir string) {
+	st := open(t, b, dir)
+
+	const n = 50
+	links := make([]string, n)
+	for i := range links {
+		links[i] = fmt.Sprintf("link%d.com", i)
+	}
+	id := mustCreate(t, st, links...)
+
+	var wg sync.WaitGroup
+	for _, url := range links {
+		wg.Go(func() {
+			if err := st.UpdateLinkResult(id, url, result(url, models.StateProcessing)); err != nil {
+				t.Errorf("update %s: %v", url, err)
+			}
+			if err := st.UpdateLinkResult(id, url, result(url, models.StateAvailable)); err != nil {
+				t.Errorf("update %s: %v", url, err)
+			}
+		})
+	}
+	wg.Wait()
+
+	s := mustGet(t, st, id)
+	if len(s.Results) != n {
+		t.Fatalf("lost updates: expected %d results, got %d", n, len(s.Results))
+	}
+	for url, r := range s.Results {
+		if r.State != models.StateAvailable {
+			t.Errorf("%s: expected available, got %s", url, r.State)
+		}
+	}
+	if s.Status != models.SetDone {
+		t.Errorf("expected done, got %s", s.Status)
+	}
+}
+
+func testListUnfinished(t *testing.T, b Backend, dir string) {
+	st := open(t, b, dir)
+
+	done := mustCreate(t, st, "a.com")
+	mustUpdate(t, st, done, result("a.com", models.StateAvailable))
+	pending := mustCreate(t, st, "b.com")
+	processing := mustCreate(t, st, "c.com")
+	mustUpdate(t, st, processing, result("c.com", models.StateProcessing))
+
+	unfinished, err := st.ListUnfinished()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := map[int64]bool{}
+	for _, s := range unfinished {
+		ids[s.ID] = true
+	}
+	if len(unfinished) != 2 || !ids[pending] || !ids[processing] {
+		t.Errorf("expected unfinished %d and %d, got %v", pending, processing, ids)
+	}
+}
+
+func testListPage(t *testing.T, b Backend, dir string) {
+	st := open(t, b, dir)
+
+	var ids []int64
+	for i := 0; i < 5; i++ {
+		ids = append(ids, mustCreate(t, st, fmt.Sprintf("link%d.com", i)))
+		time.Sleep(2 * time.Millisecond)
+	}
+	for _, id := range ids[:3] {
+		s := mustGet(t, st, id)
+		mustUpdate(t, st, id, result(s.Links[0], models.StateAvailable))
+	}
+	scheduled, _, _ := st.CreateScheduledSet(9, []string{"s.com"})
+
+	page, total, err := st.ListPage(models.SetQuery{Status: models.SetDone, Limit: 2, Offset: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 3 || len(page) != 2 || page[0].ID != ids[1] || page[1].ID != ids[0] {
+		t.Errorf("status filter with pagination: expected [%d %d] of 3, got %d of %d", ids[1], ids[0], len(page), total)
+	}
+	if len(page) > 0 && page[0].Results[page[0].Links[0]] == nil {
+		t.Error("ListPage must return sets with results")
+	}
+
+	third := mustGet(t, st, ids[2])
+	page, total, _ = st.ListPage(models.SetQuery{CreatedAfter: third.CreatedAt})
+	if total != 3 || page[len(page)-1].ID != ids[3] {
+		t.Errorf("created_after: expected 3 sets newer than %d, got %d", ids[2], total)
+	}
+
+	page, total, _ = st.ListPage(models.SetQuery{ScheduleID: 9})
+	if total != 1 || page[0].ID != scheduled {
+		t.Errorf("schedule filter: expected set %d, got %d sets", scheduled, total)
+	}
+
+	page, total, _ = st.ListPage(models.SetQuery{Offset: 100})
+	if total != 6 || len(page) != 0 {
+		t.Errorf("offset past the end: expected empty page of 6, got %d of %d", len(page), total)
+	}
+}
+
+func testWalk(t *testing.T, b Backend, dir string) {
+	st := open(t, b, dir)
+
+	n := 2*store.PageSize + 3
+	for i := range n {
+		id := mustCreate(t, st, "a.com")
+		if i%2 == 0 {
+			mustUpdate(t, st, id, result("a.com", models.StateAvailable))
+		}
+	}
+
+	var (
+		prev    int64
+		visited int
+		checked int
+	)
+	err := store.Walk(st, func(s *models.LinkSet) error {
+		if prev != 0 && s.ID >= prev {
+			t.Fatalf("expected newest first, got %d after %d", s.ID, prev)
+		}
+		prev = s.ID
+		visited++
+		if s.Results["a.com"] != nil {
+			checked++
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if visited != n || checked != (n+1)/2 {
+		t.Errorf("expected %d sets with %d results, got %d with %d", n, (n+1)/2, visited, checked)
+	}
+}
+
+func testDeleteSet(t *testing.T, b Backend, dir string) {
+	st := open(t, b, dir)
+	id := mustCreate(t, st, "a.com")
+	mustUpdate(t, st, id, result("a.com", models.StateAvailable))
+
+	if err := st.DeleteSet(id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.GetSet(id); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("deleted set: expected ErrNotFound, got %v", err)
+	}
+	if _, total, _ := st.ListPage(models.SetQuery{}); total != 0 {
+		t.Errorf("deleted set still listed, total %d", total)
+	}
+}
+
+func testImportSet(t *testing.T, b Backend, dir string) {
+	st := open(t, b, dir)
+	existing := mustCreate(t, st, "a.com")
+
+	created := time.Now().Add(-time.Hour).Truncate(time.Millisecond)
+	in := &models.LinkSet{
+		ID:         existing + 10,
+		Links:      []string{"b.com", "c.com"},
+		Results:    map[string]*models.LinkResult{"b.com": {URL: "b.com", State: models.StateAvailable, LatencyMs: 42}},
+		Status:     models.SetProcessing,
+		CreatedAt:  created,
+		UpdatedAt:  created,
+		ScheduleID: 3,
+	}
+	id, err := st.ImportSet(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != in.ID {
+		t.Errorf("free id must be kept: expected %d, got %d", in.ID, id)
+	}
+
+	s := mustGet(t, st, id)
+	if s.Status != models.SetProcessing || s.ScheduleID != 3 || !s.CreatedAt.Equal(created) ||
+		s.Results["b.com"] == nil || s.Results["b.com"].LatencyMs != 42 {
+		t.Errorf("imported set changed: %+v", s)
+	}
+
+	//id уже выдан - набор получает новый
+	in.ID = existing
+	again, err := st.ImportSet(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again <= id {
+		t.Errorf("colliding id must be replaced by a new one, got %d", again)
+	}
+	if s := mustGet(t, st, existing); len(s.Links) != 1 {
+		t.Errorf("existing set overwritten by import: %+v", s)
+	}
+
+	if next := mustCreate(t, st, "d.com"); next <= again {
+		t.Errorf("ids must grow after import: %d then %d", again, next)
+	}
+
+	//импортированный набор продолжает обновляться
+	mustUpdate(t, st, id, result("c.com", models.StateAvailable))
+	if s := mustGet(t, st, id); s.Status != models.SetDone {
+		t.Errorf("expected done after update, got %s", s.Status)
+	}
+}
+
+func testCancelSet(t *testing.T, b Backend, dir string) {
+	st := open(t, b, dir)
+	rec := &recorder{}
+	st.SetNotifier(rec)
+
+	id := mustCreate(t, st, "a.com", "b.com", "c.com")
+	mustUpdate(t, st, id, result("a.com", models.StateAvailable))
+	mustUpdate(t, st, id, result("b.com", models.StateProcessing))
+
+	s, err := st.CancelSet(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Status != models.SetCancelled {
+		t.Fatalf("expected cancelled, got %s", s.Status)
+	}
+	s = mustGet(t, st, id)
+	if s.Status != models.SetCancelled || s.Results["a.com"].State != models.StateAvailable ||
+		s.Results["b.com"].State != models.StateCancelled || s.Results["c.com"] == nil || s.Results["c.com"].State != models.StateCancelled {
+		t.Fatalf("checked links must stay, unchecked must be cancelled: %+v", s.Results)
+	}
+	if len(rec.events) != 4 || !strings.HasSuffix(rec.events[3], "cancelled cancelled") {
+		t.Errorf("expected notifications for cancelled links, got %v", rec.events)
+	}
+
+	//результат, пришедший после отмены, не сохраняется
+	if err := st.UpdateLinkResult(id, "b.com", result("b.com", models.StateAvailable)); !errors.Is(err, store.ErrCancelled) {
+		t.Errorf("update after cancel: expected ErrCancelled, got %v", err)
+	}
+	if s := mustGet(t, st, id); s.Results["b.com"].State != models.StateCancelled {
+		t.Errorf("late result overwrote cancelled link: %+v", s.Results["b.com"])
+	}
+
+	if _, err := st.CancelSet(id); err != nil {
+		t.Errorf("repeated cancel must succeed, got %v", err)
+	}
+	if unfinished, _ := st.ListUnfinished(); len(unfinished) != 0 {
+		t.Errorf("cancelled set must not be unfinished, got %d", len(unfinished))
+	}
+
+	done := mustCreate(t, st, "d.com")
+	mustUpdate(t, st, done, result("d.com", models.StateAvailable))
+	if _, err := st.CancelSet(done); !errors.Is(err, store.ErrFinished) {
+		t.Errorf("cancel finished set: expected ErrFinished, got %v", err)
+	}
+	if _, err := st.CancelSet(done + 100); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("cancel unknown set: expected ErrNotFound, got %v", err)
+	}
+}
+
+func testDeadLetter(t *testing.T, b Backend, dir string) {
+	st := open(t, b, dir)
+	first := mustCreate(t, st, "a.com")
+	second := mustCreate(t, st, "b.com")
+
+	failed := time.Now().Add(-time.Minute).Truncate(time.Millisecond)
+	for _, id := range []int64{second, first} {
+		if err := st.DeadLetter(models.DeadLetter{SetID: id, Reason: "boom", Deliveries: 5, FailedAt: failed}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := st.DeadLetter(models.DeadLetter{SetID: second + 100}); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("dead letter for unknown set: expected ErrNotFound, got %v", err)
+	}
+
+	d, err := st.GetDeadLetter(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.SetID != first || d.Reason != "boom" || d.Deliveries != 5 || !d.FailedAt.Equal(failed) {
+		t.Errorf("dead letter changed: %+v", d)
+	}
+	if list, _ := st.ListDeadLetters(); len(list) != 2 || list[0].SetID != first || list[1].SetID != second {
+		t.Errorf("expected dead letters [%d %d], got %+v", first, second, list)
+	}
+	if unfinished, _ := st.ListUnfinished(); len(unfinished) != 0 {
+		t.Errorf("dead-lettered sets must not be unfinished, got %d", len(unfinished))
+	}
+	if _, err := st.GetSet(first); err != nil {
+		t.Errorf("dead-lettered set must stay in store: %v", err)
+	}
+
+	//повторная запись обновляет причину
+	st.DeadLetter(models.DeadLetter{SetID: first, Reason: "again", Deliveries: 6, FailedAt: failed})
+	if d, _ := st.GetDeadLetter(first); d.Reason != "again" || d.Deliveries != 6 {
+		t.Errorf("dead letter not replaced: %+v", d)
+	}
+
+	if err := st.RemoveDeadLetter(first); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.RemoveDeadLetter(first); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("repeated remove: expected ErrNotFound, got %v", err)
+	}
+	if unfinished, _ := st.ListUnfinished(); len(unfinished) != 1 || unfinished[0].ID != first {
+		t.Errorf("removed dead letter must return set %d to unfinished, got %v", first, unfinished)
+	}
+
+	if err := st.DeleteSet(second); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.GetDeadLetter(second); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("deleted set: expected dead letter removed, got %v", err)
+	}
+	if list, _ := st.ListDeadLetters(); len(list) != 0 {
+		t.Errorf("expected no dead letters, got %+v", list)
+	}
+}
+
+func testSchedules(t *testing.T, b Backend, dir string) {
+	st := open(t, b, dir)
+	setID := mustCreate(t, st, "a.com")
+
+	next := time.Now().Add(time.Hour).Truncate(time.Second)
+	sc, err := st.CreateSchedule(models.Schedule{SetID: setID, Interval: "1h", NextRunAt: next})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sc.ID == 0 || sc.CreatedAt.IsZero() {
+		t.Fatalf("CreateSchedule must assign id and created_at, got %+v", sc)
+	}
+	second, _ := st.CreateSchedule(models.Schedule{SetID: setID, Cron: "* * * * *"})
+	if second.ID <= sc.ID {
+		t.Errorf("schedule ids must grow: %d then %d", sc.ID, second.ID)
+	}
+
+	sc.LastRunID = 77
+	if err := st.UpdateSchedule(sc); err != nil {
+		t.Fatal(err)
+	}
+	got, err := st.GetSchedule(sc.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.LastRunID != 77 || got.Interval != "1h" || !got.NextRunAt.Equal(next) {
+		t.Errorf("schedule not updated: %+v", got)
+	}
+
+	list, _ := st.ListSchedules()
+	if len(list) != 2 || list[0].ID != sc.ID {
+		t.Errorf("expected 2 schedules ordered by id, got %+v", list)
+	}
+
+	if err := st.DeleteSchedule(sc.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.UpdateSchedule(sc); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("update of deleted schedule: expected ErrNotFound, got %v", err)
+	}
+}
+
+func testHistory(t *testing.T, b Backend, dir string) {
+	st := open(t, b, dir)
+
+	start := time.Now()
+	for i, state := range []models.LinkState{models.StateAvailable, models.StateTimeout, models.StateAvailable} {
+		id := mustCreate(t, st, "a.com")
+		mustUpdate(t, st, id, result("a.com", models.StateProcessing))
+		res := result("a.com", state)
+		res.CheckedAt = start.Add(time.Duration(i) * time.Minute)
+		mustUpdate(t, st, id, res)
+	}
+
+	history, err := st.History("a.com", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 3 || history[1].State != models.StateTimeout {
+		t.Fatalf("expected 3 terminal results in order, got %+v", history)
+	}
+
+	//попытки перед повтором пишутся как processing, повторная запись итогового результата не дублируется
+	id := mustCreate(t, st, "b.com")
+	retry := result("b.com", models.StateProcessing)
+	retry.StatusCode = 503
+	mustUpdate(t, st, id, retry)
+	final := result("b.com", models.StateServerError)
+	mustUpdate(t, st, id, final)
+	mustUpdate(t, st, id, final)
+	if h, _ := st.History("b.com", time.Time{}); len(h) != 1 {
+		t.Errorf("expected one history entry per check, got %d", len(h))
+	}
+
+	recent, _ := st.History("a.com", start.Add(30*time.Second))
+	if len(recent) != 2 {
+		t.Errorf("since filter: expected 2 results, got %d", len(recent))
+	}
+
+	if empty, err := st.History("never-checked.com", time.Time{}); err != nil || len(empty) != 0 {
+		t.Errorf("unknown url: expected empty history, got %v, %v", empty, err)
+	}
+}
+
+type recorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *recorder) LinkUpdated(set *models.LinkSet, url string, res models.LinkResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, fmt.Sprintf("%d %s %s %s", set.ID, url, res.State, set.Status))
+}
+
+func testNotifier(t *testing.T, b Backend, dir string) {
+	st := open(t, b, dir)
+	rec := &recorder{}
+	st.SetNotifier(rec)
+
+	id := mustCreate(t, st, "a.com")
+	mustUpdate(t, st, id, result("a.com", models.StateProcessing))
+	mustUpdate(t, st, id, result("a.com", models.StateAvailable))
+
+	want := []string{
+		fmt.Sprintf("%d a.com processing processing", id),
+		fmt.Sprintf("%d a.com available done", id),
+	}
+	if fmt.Sprint(rec.events) != fmt.Sprint(want) {
+		t.Errorf("expected notifications %v, got %v", want, rec.events)
+	}
+}
+
+func testIDMonotonicity(t *testing.T, b Backend, dir string) {
+	st := b.Open(t, dir)
+
+	first := mustCreate(t, st, "a.com")
+	second := mustCreate(t, st, "b.com")
+	if second <= first {
+		t.Fatalf("ids must grow: %d then %d", first, second)
+	}
+
+	//id удаленного набора не переиспользуется
+	if err := st.DeleteSet(second); err != nil {
+		t.Fatal(err)
+	}
+	third := mustCreate(t, st, "c.com")
+	if third <= second {
+		t.Errorf("id reused after delete: %d then %d", second, third)
+	}
+	st.Close()
+
+	if !b.Persistent {
+		return
+	}
+
+	st = open(t, b, dir)
+	if fourth := mustCreate(t, st, "d.com"); fourth <= third {
+		t.Errorf("id reused after restart: %d then %d", third, fourth)
+	}
+}
+
+func testRestartRecovery(t *testing.T, b Backend, dir string) {
+	if !b.Persistent {
+		t.Skip("backend does not persist data")
+	}
+
+	st := b.Open(t, dir)
+	done := mustCreate(t, st, "a.com")
+	mustUpdate(t, st, done, result("a.com", models.StateAvailable))
+
+	unfinished := mustCreate(t, st, "b.com", "c.com")
+	mustUpdate(t, st, unfinished, result("b.com", models.StateClientError)) //c.com еще не проверялась
+
+	sc, _ := st.CreateSchedule(models.Schedule{SetID: done, Interval: "1h"})
+	st.Close()
+
+	st = open(t, b, dir)
+	list, err := st.ListUnfinished()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].ID != unfinished {
+		t.Fatalf("expected set %d to be recovered as unfinished, got %v", unfinished, list)
+	}
+	if r := list[0].Results["b.com"]; r == nil || r.State != models.StateClientError {
+		t.Errorf("finished link result lost on restart: %+v", r)
+	}
+
+	if s := mustGet(t, st, done); s.Status != models.SetDone || s.Results["a.com"].LatencyMs != 42 {
+		t.Errorf("done set changed on restart: %+v", s)
+	}
+	if _, err := st.GetSchedule(sc.ID); err != nil {
+		t.Errorf("schedule lost on restart: %v", err)
+	}
+	if history, _ := st.History("a.com", time.Time{}); len(history) != 1 {
+		t.Errorf("history lost on restart: %v", history)
+	}
+}
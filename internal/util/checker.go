@@ -0,0 +1,44 @@
+package util
+
+import (
+	"context"
+	"time"
+)
+
+// Checker проверяет доступность одной ссылки. Отмена ctx прерывает проверку,
+// результат тогда получает состояние cancelled (или timeout, если истек дедлайн)
+type Checker interface {
+	Check(ctx context.Context, url string) CheckResult
+}
+
+// CheckerFunc позволяет использовать обычную функцию как Checker
+type CheckerFunc func(ctx context.Context, url string) CheckResult
+
+func (f CheckerFunc) Check(ctx context.Context, url string) CheckResult { return f(ctx, url) }
+
+// Middleware оборачивает Checker (кеш, повторы, метрики и т.д.)
+type Middleware func(Checker) Checker
+
+// Chain оборачивает c в middleware, первый в списке становится внешним
+func Chain(c Checker, mws ...Middleware) Checker {
+	for i := len(mws) - 1; i >= 0; i-- {
+		c = mws[i](c)
+	}
+	return c
+}
+
+// WithTimeout ограничивает время одной проверки ссылки (дедлайн на ссылку), 0 - без ограничения.
+// Истекший дедлайн дает состояние timeout. Ставится внутренним в Chain, чтобы
+// ожидание слота HostLimiter не съедало время проверки
+func WithTimeout(d time.Duration) Middleware {
+	return func(next Checker) Checker {
+		if d <= 0 {
+			return next
+		}
+		return CheckerFunc(func(ctx context.Context, raw string) CheckResult {
+			ctx, cancel := context.WithTimeout(ctx, d)
+			defer cancel()
+			return next.Check(ctx, raw)
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package util
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HostLimiter ограничивает число одновременных запросов к одному хосту
+// и выдерживает минимальную паузу между обращениями к нему
+type HostLimiter struct {
+	maxPerHost int
+	delay      time.Duration
+
+	mu      sync.Mutex
+	hosts   map[string]*hostSlot
+	sweepAt time.Time //когда в следующий раз убирать простаивающие хосты
+}
+
+type hostSlot struct {
+	sem   chan struct{} //занятые слоты
+	users int           //Acquire, которые ждут или держат слот; под HostLimiter.mu
+	mu    sync.Mutex
+	next  time.Time //раньше этого времени к хосту не обращаемся
+}
+
+// sweepEvery - как часто HostLimiter убирает хосты без запросов, чтобы map не рос с каждым новым хостом
+const sweepEvery = time.Minute
+
+func NewHostLimiter(maxPerHost int, delay time.Duration) *HostLimiter {
+	if maxPerHost < 1 {
+		maxPerHost = 1
+	}
+	return &HostLimiter{
+		maxPerHost: maxPerHost,
+		delay:      delay,
+		hosts:      make(map[string]*hostSlot),
+	}
+}
+
+func (l *HostLimiter) slot(host string) *hostSlot {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now := time.Now(); now.After(l.sweepAt) {
+		l.sweep(now)
+	}
+	s, ok := l.hosts[host]
+	if !ok {
+		s = &hostSlot{sem: make(chan struct{}, l.maxPerHost)}
+		l.hosts[host] = s
+	}
+	s.users++
+	return s
+}
+
+func (l *HostLimiter) leave(s *hostSlot) {
+	l.mu.Lock()
+	s.users--
+	l.mu.Unlock()
+}
+
+// sweep убирает хосты без запросов, у которых прошла пауза, вызывается под l.mu
+func (l *HostLimiter) sweep(now time.Time) {
+	for host, s := range l.hosts {
+		if s.users > 0 {
+			continue
+		}
+		s.mu.Lock()
+		idle := !s.next.After(now)
+		s.mu.Unlock()
+		if idle {
+			delete(l.hosts, host)
+		}
+	}
+	l.sweepAt = now.Add(sweepEvery)
+}
+
+// Hosts - сколько хостов отслеживает limiter после очистки простаивающих
+func (l *HostLimiter) Hosts() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(time.Now())
+	return len(l.hosts)
+}
+
+// Acquire ждет свободный слот и паузу для хоста, возвращает функцию освобождения слота.
+// Ошибка - ctx отменен раньше, слот тогда не занят
+func (l *HostLimiter) Acquire(ctx context.Context, host string) (func(), error) {
+	s := l.slot(host)
+	select {
+	case s.sem <- struct{}{}:
+	case <-ctx.Done():
+		l.leave(s)
+		return nil, ctx.Err()
+	}
+
+	s.mu.Lock()
+	now := time.Now()
+	start := s.next
+	if start.Before(now) {
+		start = now
+	}
+	s.next = start.Add(l.delay) //резервируем время следующего обращения
+	s.mu.Unlock()
+
+	release := func() {
+		<-s.sem
+		l.leave(s)
+	}
+
+	t := time.NewTimer(time.Until(start))
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-ctx.Done():
+		release() //зарезервированное время пропадет, следующий запрос к хосту подождет лишнюю паузу
+		return nil, ctx.Err()
+	}
+
+	return release, nil
+}
+
+// WithHostLimit - middleware, пропускающий проверки через HostLimiter
+func WithHostLimit(l *HostLimiter) Middleware {
+	return func(next Checker) Checker {
+		return CheckerFunc(func(ctx context.Context, raw string) CheckResult {
+			release, err := l.Acquire(ctx, hostKey(raw))
+			if err != nil {
+				return errorResult(err)
+			}
+			defer release()
+			return next.Check(ctx, raw)
+		})
+	}
+}
+
+// hostKey - хост ссылки без www, чтобы google.com и www.google.com считались одним хостом
+func hostKey(raw string) string {
+	mainURL, _ := normalize(raw)
+	parsed, err := url.Parse(mainURL)
+	if err != nil {
+		return strings.ToLower(raw)
+	}
+	return strings.TrimPrefix(strings.ToLower(parsed.Hostname()), "www.")
+}
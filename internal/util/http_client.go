@@ -1,13 +1,51 @@
 package util
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"net"
 	"net/http"
 	"net/url"
+	"os"
 	"strings"
+	"syscall"
 	"time"
+
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/models"
 )
 
+const userAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36"
+
+// классы ошибок проверки
+const (
+	ErrClassDNS        = "dns"
+	ErrClassTimeout    = "timeout"
+	ErrClassTLS        = "tls"
+	ErrClassRefused    = "connection_refused"
+	ErrClassRedirect   = "redirect_loop"
+	ErrClassNetwork    = "network"
+	ErrClassHTTPStatus = "http_status"
+	ErrClassCancelled  = "cancelled" //проверку отменили через context
+)
+
+const maxRedirects = 10
+
+var errRedirectLoop = errors.New("redirect loop")
+
+// результат проверки одной ссылки
+type CheckResult struct {
+	OK         bool
+	State      models.LinkState
+	StatusCode int
+	Method     string //метод, которым получен ответ (HEAD/GET)
+	FinalURL   string //url после редиректов
+	Latency    time.Duration
+	ErrorClass string
+	Detail     string
+}
+
 func Now() time.Time { return time.Now() }
 
 func normalize(raw string) (string, []string) {
@@ -42,50 +80,214 @@ func normalize(raw string) (string, []string) {
 	return mainURL, variants
 }
 
-var CheckURL = func(raw string) (bool, string) {
-	_, candidates := normalize(raw)
+// classifyError определяет класс сетевой ошибки по цепочке ошибок net/http
+func classifyError(err error) string {
+	if errors.Is(err, errRedirectLoop) {
+		return ErrClassRedirect
+	}
+	if errors.Is(err, context.Canceled) {
+		return ErrClassCancelled
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		if dnsErr.IsTimeout {
+			return ErrClassTimeout
+		}
+		return ErrClassDNS
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, os.ErrDeadlineExceeded) {
+		return ErrClassTimeout
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ErrClassTimeout
+	}
+
+	var (
+		certErr    *tls.CertificateVerificationError
+		recordErr  tls.RecordHeaderError
+		unknownCA  x509.UnknownAuthorityError
+		hostErr    x509.HostnameError
+		invalidErr x509.CertificateInvalidError
+	)
+	if errors.As(err, &certErr) || errors.As(err, &recordErr) || errors.As(err, &unknownCA) ||
+		errors.As(err, &hostErr) || errors.As(err, &invalidErr) {
+		return ErrClassTLS
+	}
+
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return ErrClassRefused
+	}
+
+	return ErrClassNetwork
+}
+
+// stateFor переводит результат проверки в состояние ссылки для разбора ошибок дежурными
+func stateFor(res CheckResult) models.LinkState {
+	if res.OK {
+		return models.StateAvailable
+	}
+
+	switch res.ErrorClass {
+	case ErrClassDNS:
+		return models.StateDNSError
+	case ErrClassTimeout:
+		return models.StateTimeout
+	case ErrClassTLS:
+		return models.StateTLSError
+	case ErrClassRefused:
+		return models.StateConnectionRefused
+	case ErrClassRedirect:
+		return models.StateRedirectLoop
+	case ErrClassCancelled:
+		return models.StateCancelled
+	case ErrClassHTTPStatus:
+		switch {
+		case res.StatusCode >= 500:
+			return models.StateServerError
+		case res.StatusCode >= 400:
+			return models.StateClientError
+		}
+	}
+	return models.StateNotAvailable
+}
+
+// checkRedirect останавливает цепочку редиректов, если url повторился или редиректов слишком много
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return errRedirectLoop
+	}
+	for _, prev := range via {
+		if prev.URL.String() == req.URL.String() {
+			return errRedirectLoop
+		}
+	}
+	return nil
+}
+
+// errorResult - результат проверки, которая не дошла до запроса (отмена, ожидание лимита)
+func errorResult(err error) CheckResult {
+	res := CheckResult{ErrorClass: classifyError(err), Detail: err.Error()}
+	res.State = stateFor(res)
+	return res
+}
+
+// probe - один запрос, не дольше timeout и не дольше дедлайна ctx
+func probe(ctx context.Context, client *http.Client, timeout time.Duration, method, u string) (CheckResult, error) {
+	res := CheckResult{Method: method, FinalURL: u}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, method, u, nil)
+	if err != nil {
+		res.ErrorClass = ErrClassNetwork
+		res.State = stateFor(res)
+		res.Detail = err.Error()
+		return res, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	res.Latency = time.Since(start)
+	if err != nil {
+		res.ErrorClass = classifyError(err)
+		res.State = stateFor(res)
+		res.Detail = err.Error()
+		return res, err
+	}
+	resp.Body.Close()
 
-	client := &http.Client{
-		Timeout: 8 * time.Second,
-		Transport: &http.Transport{
-			Proxy:               http.ProxyFromEnvironment,
-			DialContext:         (&net.Dialer{Timeout: 3 * time.Second}).DialContext,
-			TLSHandshakeTimeout: 3 * time.Second,
+	res.StatusCode = resp.StatusCode
+	res.FinalURL = resp.Request.URL.String() //resp.Request - последний запрос в цепочке редиректов
+	if resp.StatusCode >= 200 && resp.StatusCode < 400 {
+		res.OK = true
+		res.Detail = "ok"
+	} else {
+		res.ErrorClass = ErrClassHTTPStatus
+		res.Detail = resp.Status
+	}
+	res.State = stateFor(res)
+	return res, nil
+}
+
+const defaultRequestTimeout = 8 * time.Second
+
+// HTTPChecker - проверка ссылок через HEAD/GET запросы, реализация Checker по умолчанию
+type HTTPChecker struct {
+	client  *http.Client
+	timeout time.Duration //на один запрос, общий дедлайн ссылки задает ctx (WithTimeout)
+}
+
+type HTTPCheckerOption func(*HTTPChecker)
+
+// WithRequestTimeout - время на один HEAD или GET запрос, по умолчанию 8s
+func WithRequestTimeout(d time.Duration) HTTPCheckerOption {
+	return func(c *HTTPChecker) {
+		if d > 0 {
+			c.timeout = d
+		}
+	}
+}
+
+func NewHTTPChecker(opts ...HTTPCheckerOption) *HTTPChecker {
+	c := &HTTPChecker{
+		client: &http.Client{
+			CheckRedirect: checkRedirect,
+			Transport: &http.Transport{
+				Proxy:               http.ProxyFromEnvironment,
+				DialContext:         (&net.Dialer{Timeout: 3 * time.Second}).DialContext,
+				TLSHandshakeTimeout: 3 * time.Second,
+			},
 		},
+		timeout: defaultRequestTimeout,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func (c *HTTPChecker) Check(ctx context.Context, raw string) CheckResult {
+	_, candidates := normalize(raw)
+	client := c.client
+
+	last := CheckResult{State: models.StateNotAvailable, Detail: "not available"}
+	//вариант с www только запасной: его "no such host" не должен затирать ответ основного url
+	remember := func(res CheckResult) {
+		if last.Method == "" || res.ErrorClass != ErrClassDNS {
+			last = res
+		}
 	}
+
 	for _, u := range candidates {
-		req, _ := http.NewRequest("HEAD", u, nil)
-		req.Header.Set("User-Agent",
-			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36")
-		resp, err := client.Do(req)
+		if err := ctx.Err(); err != nil { //отмена: остальные варианты url не пробуем
+			return errorResult(err)
+		}
 
+		res, err := probe(ctx, client, c.timeout, http.MethodHead, u)
 		if err == nil {
-			resp.Body.Close()
-			if resp.StatusCode >= 200 && resp.StatusCode < 400 {
-				return true, "ok"
+			if res.OK {
+				return res
 			}
+			remember(res)
 			continue
 		}
-		req2, _ := http.NewRequest("GET", u, nil)
-		req2.Header.Set("User-Agent",
-			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36")
-
-		resp2, err2 := client.Do(req2)
-		if err2 == nil {
-			resp2.Body.Close()
-			if resp2.StatusCode >= 200 && resp2.StatusCode < 400 {
-				return true, "ok"
-			}
-			continue
+
+		if ctx.Err() != nil {
+			return res
 		}
 
-		t := strings.ToLower(err2.Error())
-		if strings.Contains(t, "no such host") {
-			continue
+		//некоторые сервера не поддерживают HEAD, пробуем GET
+		res, _ = probe(ctx, client, c.timeout, http.MethodGet, u)
+		if res.OK {
+			return res
 		}
+		remember(res)
 	}
 
-	return false, "not available"
+	return last
 }
-
-
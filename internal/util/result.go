@@ -0,0 +1,34 @@
+package util
+
+import "github.com/EugeneKrivoshein/14_11_2025_linkChecker/models"
+
+// ToLinkResult переводит результат проверки в модель для хранения
+func ToLinkResult(url string, c CheckResult) models.LinkResult {
+	res := models.LinkResult{
+		URL:        url,
+		CheckedAt:  Now(),
+		Detail:     c.Detail,
+		StatusCode: c.StatusCode,
+		Method:     c.Method,
+		FinalURL:   c.FinalURL,
+		LatencyMs:  c.Latency.Milliseconds(),
+		ErrorClass: c.ErrorClass,
+	}
+	res.State = c.State
+	if res.State == "" {
+		if c.OK {
+			res.State = models.StateAvailable
+		} else {
+			res.State = models.StateNotAvailable
+		}
+	}
+
+	res.Attempts = []models.LinkAttempt{{
+		At:         res.CheckedAt,
+		State:      res.State,
+		StatusCode: res.StatusCode,
+		LatencyMs:  res.LatencyMs,
+		Detail:     res.Detail,
+	}}
+	return res
+}
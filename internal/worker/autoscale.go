@@ -0,0 +1,155 @@
+package worker
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// AutoscalePolicy - границы и пороги автоматического размера пула. Пул растет, пока в очереди
+// ждут наборы, и сжимается по одному worker, когда очередь пуста. Если ссылки в среднем
+// проверяются дольше MaxLatency, хосты уже не успевают отвечать: пул не растет, а сжимается
+type AutoscalePolicy struct {
+	Min        int
+	Max        int           //0 - автоскейлинг выключен
+	Interval   time.Duration //как часто пересчитывать размер
+	Backlog    int           //сколько ждущих наборов приходится на одного добавленного worker
+	MaxLatency time.Duration //0 - без ограничения
+}
+
+const (
+	defaultAutoscaleInterval = 10 * time.Second
+	defaultAutoscaleBacklog  = 4
+)
+
+func (p AutoscalePolicy) Enabled() bool {
+	return p.Max > 0
+}
+
+// Next - размер пула на следующий интервал при текущем размере cur, pending наборах в очереди и загрузке s
+func (p AutoscalePolicy) Next(cur, pending int, s PoolStats) int {
+	backlog := p.Backlog
+	if backlog <= 0 {
+		backlog = defaultAutoscaleBacklog
+	}
+
+	n := cur
+	switch {
+	case p.MaxLatency > 0 && s.AvgLatency > p.MaxLatency:
+		n = cur - 1
+	case pending > 0:
+		n = max(cur, s.Busy+(pending+backlog-1)/backlog)
+	case s.Busy < cur:
+		n = cur - 1 //очередь пуста и есть простаивающие worker
+	}
+	return min(max(n, p.Min, 1), p.Max, MaxWorkers)
+}
+
+// Autoscaler периодически меняет размер пула Manager по AutoscalePolicy
+type Autoscaler struct {
+	mgr *Manager
+
+	mu     sync.Mutex
+	policy AutoscalePolicy
+
+	reset    chan struct{} //политика изменилась, нужно перезапустить тикер
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+func NewAutoscaler(mgr *Manager, p AutoscalePolicy) *Autoscaler {
+	return &Autoscaler{
+		mgr:    mgr,
+		policy: withDefaults(p),
+		reset:  make(chan struct{}, 1),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+func withDefaults(p AutoscalePolicy) AutoscalePolicy {
+	if p.Interval <= 0 {
+		p.Interval = defaultAutoscaleInterval
+	}
+	if p.Backlog <= 0 {
+		p.Backlog = defaultAutoscaleBacklog
+	}
+	p.Min = max(p.Min, 1)
+	return p
+}
+
+func (a *Autoscaler) Run() {
+	defer close(a.done)
+
+	for {
+		p := a.Policy()
+		if !p.Enabled() { //ждем, пока политику не включат
+			if !a.wait(nil) {
+				return
+			}
+			continue
+		}
+
+		t := time.NewTicker(p.Interval)
+		again := a.wait(t.C)
+		t.Stop()
+		if !again {
+			return
+		}
+	}
+}
+
+// wait выполняет шаги по tick, пока не изменится политика (true) или не будет вызван Stop (false)
+func (a *Autoscaler) wait(tick <-chan time.Time) bool {
+	for {
+		select {
+		case <-a.stop:
+			return false
+		case <-a.reset:
+			return true
+		case <-tick:
+			a.step()
+		}
+	}
+}
+
+func (a *Autoscaler) Stop() {
+	a.stopOnce.Do(func() { close(a.stop) })
+	<-a.done
+}
+
+func (a *Autoscaler) Policy() AutoscalePolicy {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.policy
+}
+
+// SetPolicy заменяет политику на лету (перечитывание конфигурации)
+func (a *Autoscaler) SetPolicy(p AutoscalePolicy) {
+	a.mu.Lock()
+	a.policy = withDefaults(p)
+	a.mu.Unlock()
+
+	select {
+	case a.reset <- struct{}{}:
+	default:
+	}
+}
+
+// step - один пересчет размера пула
+func (a *Autoscaler) step() {
+	p := a.Policy()
+	if !p.Enabled() {
+		return
+	}
+
+	s := a.mgr.PoolStats()
+	n := p.Next(s.Workers, a.mgr.queue.Stats().Pending, s)
+	if n == s.Workers {
+		return
+	}
+	if err := a.mgr.Resize(n); err != nil {
+		log.Printf("autoscale: %v", err)
+	}
+}
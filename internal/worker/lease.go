@@ -0,0 +1,326 @@
+package worker
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/queue"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/store"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/models"
+)
+
+// Аренды для удаленных worker: набор берется из той же очереди, что и у локальных worker,
+// ссылки выдаются пачкой. Пока аренда продлевается heartbeat, задача в очереди тоже продлевается.
+// Когда по всем ссылкам пришли результаты, задача подтверждается; оставшиеся ссылки набора
+// (больше лимита пачки или для повтора) ставятся в очередь снова. Просроченная аренда
+// возвращает набор в очередь, поздние результаты по ней отклоняются
+
+var (
+	ErrLeaseNotFound  = errors.New("lease not found or expired")
+	ErrLeaseCancelled = errors.New("lease cancelled")
+	ErrLeaseResult    = errors.New("bad lease result")
+)
+
+const (
+	defaultLeaseTTL   = 30 * time.Second
+	defaultLeaseLinks = 50
+	maxLeaseLinks     = 10 * defaultLeaseLinks //один worker не забирает огромный набор целиком
+)
+
+// WithLeaseTTL - на сколько выдается и продлевается аренда удаленного worker
+func WithLeaseTTL(d time.Duration) Option {
+	return func(m *Manager) {
+		if d > 0 {
+			m.leaseTTL = d
+		}
+	}
+}
+
+type lease struct {
+	id      string
+	worker  string
+	job     queue.Job
+	links   []models.LeaseLink
+	prev    map[string][]models.LinkAttempt //попытки до аренды, к ним добавляются попытки удаленного worker
+	pending map[string]bool                 //ссылки без результата
+	writing int                             //Submit, которые сохраняют результаты вне m.leaseMu
+	expires time.Time
+
+	ctx  context.Context //отменяется Cancel набора и дедлайном набора
+	done func()
+}
+
+func (l *lease) view(ttl time.Duration) models.Lease {
+	return models.Lease{ID: l.id, SetID: l.job.SetID, Links: l.links, ExpiresAt: l.expires, TTLMs: ttl.Milliseconds()}
+}
+
+// Lease выдает удаленному worker до limit ссылок одного набора (не больше maxLeaseLinks).
+// Ждет задачу, пока не отменен ctx; после Stop - ErrStopped
+func (m *Manager) Lease(ctx context.Context, worker string, limit int) (models.Lease, error) {
+	if limit <= 0 {
+		limit = defaultLeaseLinks
+	}
+	limit = min(limit, maxLeaseLinks)
+	m.reapOnce.Do(func() { go m.reapLeases() })
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer context.AfterFunc(m.idle, cancel)()
+
+	for {
+		job, err := m.queue.Pop(ctx)
+		if err != nil {
+			if m.idle.Err() != nil {
+				return models.Lease{}, ErrStopped
+			}
+			return models.Lease{}, err
+		}
+
+		set, ok := m.load(job)
+		if !ok {
+			continue
+		}
+		if l, ok := m.newLease(worker, job, set, limit); ok {
+			return l, nil
+		}
+	}
+}
+
+// newLease помечает ссылки processing и регистрирует аренду. false - задача уже подтверждена
+// (проверять нечего, набор отменен или истек его дедлайн)
+func (m *Manager) newLease(worker string, job queue.Job, set *models.LinkSet, limit int) (models.Lease, bool) {
+	l := &lease{
+		worker:  worker,
+		job:     job,
+		prev:    map[string][]models.LinkAttempt{},
+		pending: map[string]bool{},
+	}
+	l.ctx, l.done = m.Track(m.ctx, set.ID)
+
+	for _, url := range m.due(set) {
+		if len(l.links) == limit || l.ctx.Err() != nil {
+			break
+		}
+		if l.pending[url] { //повторы в Links
+			continue
+		}
+		attempts, ok := m.markProcessing(set.ID, url, set.Results[url])
+		if !ok {
+			break
+		}
+		l.links = append(l.links, models.LeaseLink{URL: url, Attempts: len(attempts)})
+		l.prev[url] = attempts
+		l.pending[url] = true
+	}
+	if l.ctx.Err() != nil { //уже помеченные ссылки получают timeout, как при снятии аренды
+		l.done()
+		m.abortLease(l)
+		return models.Lease{}, false
+	}
+	if len(l.links) == 0 {
+		l.done()
+		m.ack(job)
+		return models.Lease{}, false
+	}
+
+	l.id = newLeaseID()
+	m.leaseMu.Lock()
+	defer m.leaseMu.Unlock()
+	l.expires = time.Now().Add(m.leaseTTL)
+	m.leases[l.id] = l
+	return l.view(m.leaseTTL), true
+}
+
+// Heartbeat продлевает аренду. ErrLeaseCancelled - набор отменен или истек его дедлайн, проверку нужно прекратить
+func (m *Manager) Heartbeat(id string) (models.Lease, error) {
+	m.leaseMu.Lock()
+	l, err := m.liveLease(id)
+	if err == nil {
+		var job queue.Job
+		if job, err = m.queue.Touch(l.job); err != nil { //очередь уже выдала набор снова
+			m.dropLease(l)
+			err = ErrLeaseNotFound
+		} else {
+			l.job = job
+			l.expires = time.Now().Add(m.leaseTTL)
+		}
+	}
+	var view models.Lease
+	if err == nil {
+		view = l.view(m.leaseTTL)
+	}
+	m.leaseMu.Unlock()
+
+	if errors.Is(err, ErrLeaseCancelled) {
+		m.abortLease(l)
+	}
+	return view, err
+}
+
+// Submit сохраняет результаты аренды, повторный результат по ссылке пропускается.
+// Возвращает, сколько ссылок аренды еще без результата; на последнем результате аренда завершается.
+// Ссылки забираются из аренды под m.leaseMu, запись в store идет без блокировки
+func (m *Manager) Submit(id string, results []models.LinkResult) (int, error) {
+	m.leaseMu.Lock()
+	l, err := m.liveLease(id)
+	if err != nil {
+		m.leaseMu.Unlock()
+		if errors.Is(err, ErrLeaseCancelled) {
+			m.abortLease(l)
+		}
+		return 0, err
+	}
+	for _, r := range results {
+		if _, ok := l.prev[r.URL]; !ok {
+			m.leaseMu.Unlock()
+			return len(l.pending), fmt.Errorf("%w: %s is not leased", ErrLeaseResult, r.URL)
+		}
+		if !r.State.IsTerminal() {
+			m.leaseMu.Unlock()
+			return len(l.pending), fmt.Errorf("%w: %s has state %q", ErrLeaseResult, r.URL, r.State)
+		}
+	}
+	var claimed []models.LinkResult
+	for _, r := range results {
+		if l.pending[r.URL] {
+			delete(l.pending, r.URL)
+			claimed = append(claimed, r)
+		}
+	}
+	l.writing++
+	m.leaseMu.Unlock()
+
+	unsaved, err := m.saveResults(l, claimed)
+
+	m.leaseMu.Lock()
+	l.writing--
+	for _, r := range unsaved { //вернутся в аренду, worker отправит их снова
+		l.pending[r.URL] = true
+	}
+	left := len(l.pending)
+	done := left == 0 && l.writing == 0 && m.leases[l.id] == l
+	if done {
+		m.dropLease(l)
+	}
+	m.leaseMu.Unlock()
+
+	if done {
+		m.finishLease(l)
+	}
+	return left, err
+}
+
+// saveResults пишет результаты аренды в store. При ошибке возвращает несохраненные результаты
+func (m *Manager) saveResults(l *lease, results []models.LinkResult) ([]models.LinkResult, error) {
+	now := time.Now()
+	for i, r := range results {
+		if r.CheckedAt.IsZero() {
+			r.CheckedAt = now
+		}
+		if len(r.Attempts) == 0 {
+			r.Attempts = []models.LinkAttempt{{At: r.CheckedAt, State: r.State, StatusCode: r.StatusCode, LatencyMs: r.LatencyMs, Detail: r.Detail}}
+		}
+		r.Attempts = append(slices.Clone(l.prev[r.URL]), r.Attempts...)
+
+		err := m.store.UpdateLinkResult(l.job.SetID, r.URL, r)
+		if err != nil && !errors.Is(err, store.ErrCancelled) {
+			return results[i:], err
+		}
+		m.observe(time.Duration(r.LatencyMs) * time.Millisecond)
+	}
+	return nil, nil
+}
+
+// liveLease - действующая аренда, вызывается под m.leaseMu. Просроченная аренда возвращает набор в очередь.
+// Аренда отмененного набора снимается и возвращается с ErrLeaseCancelled: ее нужно закрыть abortLease без m.leaseMu
+func (m *Manager) liveLease(id string) (*lease, error) {
+	l, ok := m.leases[id]
+	if !ok {
+		return nil, ErrLeaseNotFound
+	}
+	if time.Now().After(l.expires) {
+		m.expireLease(l)
+		return nil, ErrLeaseNotFound
+	}
+	if l.ctx.Err() != nil {
+		m.dropLease(l)
+		return l, ErrLeaseCancelled
+	}
+	return l, nil
+}
+
+// finishLease подтверждает задачу снятой аренды, непроверенные ссылки набора снова ставятся в очередь
+func (m *Manager) finishLease(l *lease) {
+	set, err := m.store.GetSet(l.job.SetID)
+	if err == nil && set.Status != models.SetCancelled && len(m.due(set)) > 0 {
+		if err := m.queue.Push(set.ID, l.job.Priority); err != nil {
+			log.Printf("requeue set %d: %v", set.ID, err)
+		}
+	}
+	m.ack(l.job)
+}
+
+// abortLease закрывает снятую аренду отмененного набора или набора с истекшим дедлайном.
+// Ссылки без результата получают timeout, у отмененного набора store их уже пометил
+func (m *Manager) abortLease(l *lease) {
+	m.leaseMu.Lock()
+	pending := slices.Collect(maps.Keys(l.pending))
+	clear(l.pending)
+	m.leaseMu.Unlock()
+
+	now := time.Now()
+	for _, url := range pending {
+		m.save(l.job.SetID, url, timeoutResult(url, l.prev[url], now))
+	}
+	m.ack(l.job)
+}
+
+// expireLease возвращает набор просроченной аренды в очередь, выдача засчитывается как неудачная
+func (m *Manager) expireLease(l *lease) {
+	m.dropLease(l)
+	log.Printf("lease %s of set %d expired (worker %s)", l.id, l.job.SetID, l.worker)
+	if err := m.queue.Retry(l.job, 0); err != nil && !errors.Is(err, queue.ErrStale) {
+		log.Printf("retry set %d: %v", l.job.SetID, err)
+	}
+}
+
+func (m *Manager) dropLease(l *lease) {
+	delete(m.leases, l.id)
+	l.done()
+}
+
+// reapLeases возвращает в очередь просроченные аренды, по которым worker больше не обращается
+func (m *Manager) reapLeases() {
+	t := time.NewTicker(max(m.leaseTTL/4, 10*time.Millisecond))
+	defer t.Stop()
+
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-t.C:
+		}
+
+		now := time.Now()
+		m.leaseMu.Lock()
+		for _, l := range m.leases {
+			if now.After(l.expires) {
+				m.expireLease(l)
+			}
+		}
+		m.leaseMu.Unlock()
+	}
+}
+
+func newLeaseID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -1,118 +1,572 @@
 package worker
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
+	"slices"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/queue"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/store"
 	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/util"
 	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/models"
 )
 
-type StoreWorker interface {
-	GetSet(int64) (*models.LinkSet, error)
-	UpdateLinkResult(int64, string, models.LinkResult) error
-	ListUnfinished() ([]*models.LinkSet, error)
-}
-
 type Manager struct {
-	store   StoreWorker
-	jobs    chan int64
+	store   store.Store
+	checker util.Checker
+	queue   queue.Queue
 	wg      sync.WaitGroup
 	stop    chan struct{}
-	workers int
+	retry   RetryPolicy
+
+	poolMu  sync.Mutex
+	workers int                  //целевой размер пула
+	pool    []context.CancelFunc //по одной на worker, отмена - worker завершается после текущего набора
+	started bool
+	stopped bool
+	busy    atomic.Int32 //worker, проверяющие набор
+
+	idle     context.Context //ожидание задач в очереди, отменяется в Stop
+	stopIdle context.CancelFunc
+
+	ctx        context.Context //базовый для всех проверок worker, отменяется в Stop
+	cancel     context.CancelFunc
+	setTimeout time.Duration //дедлайн на проверку всего набора, 0 - без ограничения
+	grace      time.Duration //сколько Stop ждет текущие проверки перед отменой
+
+	maxDeliveries int //после стольких неудачных выдач набор уходит в dead letter
+
+	mu      sync.Mutex
+	running map[int64]map[int]context.CancelFunc //проверки наборов в работе, по ним Cancel прерывает запросы
+	nextRun int
+	latency time.Duration //скользящее среднее времени проверки ссылки
+
+	leaseMu  sync.Mutex
+	leases   map[string]*lease //аренды удаленных worker по id
+	leaseTTL time.Duration
+	reapOnce sync.Once
+}
+
+const (
+	defaultShutdownGrace = 10 * time.Second
+	DefaultMaxDeliveries = 5
+	MaxWorkers           = 256
+)
+
+var (
+	ErrStopped  = errors.New("manager stopped")
+	ErrPoolSize = errors.New("bad worker count")
+)
+
+type Option func(*Manager)
+
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(m *Manager) { m.retry = p }
+}
+
+// WithQueue - очередь наборов, по умолчанию queue.MemQueue
+func WithQueue(q queue.Queue) Option {
+	return func(m *Manager) { m.queue = q }
+}
+
+// WithSetTimeout - дедлайн на проверку набора (worker и синхронный запрос).
+// Ссылки, не проверенные к дедлайну, получают состояние timeout
+func WithSetTimeout(d time.Duration) Option {
+	return func(m *Manager) { m.setTimeout = d }
 }
 
-func NewManager(st StoreWorker, workers int) *Manager {
+// WithShutdownGrace - сколько Stop ждет текущие проверки, потом прерывает их.
+// Прерванные результаты не сохраняются, наборы проверятся после перезапуска
+func WithShutdownGrace(d time.Duration) Option {
+	return func(m *Manager) { m.grace = d }
+}
+
+// WithMaxDeliveries - сколько раз набор выдается из очереди, прежде чем попасть в dead letter.
+// n <= 0 - DefaultMaxDeliveries
+func WithMaxDeliveries(n int) Option {
+	return func(m *Manager) {
+		if n > 0 {
+			m.maxDeliveries = n
+		}
+	}
+}
+
+// если checker nil, используется util.HTTPChecker
+func NewManager(st store.Store, workers int, checker util.Checker, opts ...Option) *Manager {
+	if checker == nil {
+		checker = util.NewHTTPChecker()
+	}
+
 	m := &Manager{
 		store:   st,
-		jobs:    make(chan int64, 1000),
+		checker: checker,
 		stop:    make(chan struct{}),
-		workers: workers,
+		workers: min(max(workers, 1), MaxWorkers),
+		retry:   DefaultRetryPolicy(),
+		grace:   defaultShutdownGrace,
+		running: make(map[int64]map[int]context.CancelFunc),
+
+		maxDeliveries: DefaultMaxDeliveries,
+		leases:        make(map[string]*lease),
+		leaseTTL:      defaultLeaseTTL,
+	}
+	m.ctx, m.cancel = context.WithCancel(context.Background())
+	m.idle, m.stopIdle = context.WithCancel(context.Background())
+	for _, opt := range opts {
+		opt(m)
+	}
+	if m.queue == nil {
+		m.queue = queue.NewMemQueue()
 	}
 
-	if unfinished, err := st.ListUnfinished(); err == nil {
-		for _, s := range unfinished {
-			m.Enqueue(s.ID)
+	//сохраненная очередь уже содержит незавершенные наборы, обход store не нужен
+	if m.queue.Fresh() {
+		if unfinished, err := st.ListUnfinished(); err == nil {
+			for _, s := range unfinished {
+				if err := m.Enqueue(s.ID, queue.PriorityNormal); err != nil {
+					log.Printf("enqueue set %d: %v", s.ID, err)
+				}
+			}
 		}
 	}
 
 	return m
 }
 
+// Run запускает пул worker и ждет Stop
 func (m *Manager) Run() {
-	for i := 0; i < m.workers; i++ {
-		m.wg.Add(1)
-		go m.worker()
+	m.poolMu.Lock()
+	if !m.stopped {
+		m.started = true
+		m.scale()
 	}
+	m.poolMu.Unlock()
+
+	<-m.stop
 	m.wg.Wait()
 }
 
 func (m *Manager) Stop() {
+	m.poolMu.Lock()
+	m.stopped = true //после этого Resize не запускает новых worker и wg.Add не гонится с wg.Wait
+	m.poolMu.Unlock()
+
 	close(m.stop)
-	close(m.jobs)
-	m.wg.Wait()
-}
+	m.stopIdle()
+
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
 
-func (m *Manager) Enqueue(id int64) {
+	t := time.NewTimer(m.grace)
+	defer t.Stop()
 	select {
-	case m.jobs <- id:
-	default:
-		go func() { m.jobs <- id }()
+	case <-done:
+	case <-t.C:
+		log.Printf("worker: shutdown grace %s expired, cancelling running checks", m.grace)
+	}
+	m.cancel()
+	<-done
+}
+
+// Enqueue ставит набор в lane p очереди, queue.ErrFull - очередь заполнена
+func (m *Manager) Enqueue(id int64, p queue.Priority) error {
+	return m.queue.Push(id, p)
+}
+
+// Resize меняет размер пула на лету. Лишние worker завершаются после текущего набора,
+// до Run размер только запоминается
+func (m *Manager) Resize(n int) error {
+	if n < 1 || n > MaxWorkers {
+		return fmt.Errorf("%w: %d, expected 1..%d", ErrPoolSize, n, MaxWorkers)
+	}
+
+	m.poolMu.Lock()
+	defer m.poolMu.Unlock()
+	if m.stopped {
+		return ErrStopped
+	}
+	if n != m.workers {
+		log.Printf("worker pool: %d -> %d", m.workers, n)
+	}
+	m.workers = n
+	if m.started {
+		m.scale()
 	}
+	return nil
 }
 
-// worker проверяет ссылки для задач из очереди
-func (m *Manager) worker() {
+// PoolStats - размер и загрузка пула worker
+type PoolStats struct {
+	Workers    int           `json:"workers"`
+	Busy       int           `json:"busy"`   //может ненадолго превышать Workers, пока лишние worker доделывают набор
+	AvgLatency time.Duration `json:"-"`      //скользящее среднее времени проверки ссылки
+	Leases     int           `json:"leases"` //аренды удаленных worker
+}
+
+func (m *Manager) PoolStats() PoolStats {
+	m.poolMu.Lock()
+	workers := m.workers
+	m.poolMu.Unlock()
+
+	m.mu.Lock()
+	latency := m.latency
+	m.mu.Unlock()
+
+	m.leaseMu.Lock()
+	leases := len(m.leases)
+	m.leaseMu.Unlock()
+	return PoolStats{Workers: workers, Busy: int(m.busy.Load()), AvgLatency: latency, Leases: leases}
+}
+
+// scale доводит число запущенных worker до m.workers, вызывается под m.poolMu
+func (m *Manager) scale() {
+	for len(m.pool) < m.workers {
+		ctx, cancel := context.WithCancel(m.idle)
+		m.pool = append(m.pool, cancel)
+		m.wg.Add(1)
+		go m.worker(ctx)
+	}
+	for len(m.pool) > m.workers {
+		last := len(m.pool) - 1
+		m.pool[last]()
+		m.pool = m.pool[:last]
+	}
+}
+
+// worker проверяет ссылки для задач из очереди, пока не отменен ctx (Stop или уменьшение пула)
+func (m *Manager) worker(ctx context.Context) {
 	defer m.wg.Done()
 	for {
-		select {
-		case <-m.stop:
+		//после Stop новые задачи не берем, даже если в очереди что-то осталось
+		job, err := m.queue.Pop(ctx)
+		if err != nil {
 			return
-		case id, ok := <-m.jobs:
-			if !ok {
+		}
+		m.busy.Add(1)
+		m.process(job)
+		m.busy.Add(-1)
+	}
+}
+
+// process проверяет набор задачи job и подтверждает задачу. Проверка, прерванная
+// остановкой сервиса, возвращается в очередь. Набор, который не удалось обработать
+// за maxDeliveries выдач, уходит в dead letter
+func (m *Manager) process(job queue.Job) {
+	id := job.SetID
+	set, ok := m.load(job)
+	if !ok {
+		return
+	}
+
+	stopTouch := m.keepVisible(job)
+	ctx, done := m.Track(m.ctx, id)
+	var (
+		wg          sync.WaitGroup
+		interrupted atomic.Bool
+	)
+	for _, url := range m.due(set) {
+		wg.Go(func() {
+			if !m.checkLink(ctx, id, url, set.Results[url]) {
+				interrupted.Store(true)
+			}
+		})
+	}
+	wg.Wait()
+	done()
+	job = stopTouch()
+
+	if interrupted.Load() || m.ctx.Err() != nil { //остановка сервиса - не неудачная выдача, в dead letter не засчитывается
+		if err := m.queue.Nack(job); err != nil {
+			log.Printf("nack set %d: %v", id, err)
+		}
+		return
+	}
+	m.ack(job)
+}
+
+// load загружает набор задачи job. false - задача уже подтверждена (набор удален, отменен,
+// ушел в dead letter) или возвращена в очередь
+func (m *Manager) load(job queue.Job) (*models.LinkSet, bool) {
+	id := job.SetID
+	if job.Deliveries > m.maxDeliveries { //прошлые выдачи не подтверждены: worker падал или зависал на наборе
+		m.deadLetter(job, fmt.Sprintf("not acknowledged after %d deliveries", job.Deliveries-1))
+		return nil, false
+	}
+
+	set, err := m.store.GetSet(id)
+	if errors.Is(err, store.ErrNotFound) { //набор удален, пока ждал в очереди
+		m.ack(job)
+		return nil, false
+	}
+	if err != nil {
+		log.Printf("load set %d: %v", id, err)
+		if job.Deliveries >= m.maxDeliveries {
+			m.deadLetter(job, fmt.Sprintf("load set: %v", err))
+			return nil, false
+		}
+		m.retryLater(job)
+		return nil, false
+	}
+	if set.Status == models.SetCancelled {
+		m.ack(job)
+		return nil, false
+	}
+	return set, true
+}
+
+// due - ссылки набора, которые еще нужно проверить: без результата или с результатом для повтора
+func (m *Manager) due(set *models.LinkSet) []string {
+	var out []string
+	for _, url := range set.Links {
+		res := set.Results[url]
+		if res != nil && res.State.IsTerminal() && !m.retry.ShouldRetry(res.State, attemptsOf(res)) {
+			continue
+		}
+		out = append(out, url)
+	}
+	return out
+}
+
+// deadLetter убирает набор из очереди и из ListUnfinished до решения через /admin/dead-letters
+func (m *Manager) deadLetter(job queue.Job, reason string) {
+	d := models.DeadLetter{SetID: job.SetID, Reason: reason, Deliveries: job.Deliveries, FailedAt: time.Now()}
+	switch err := m.store.DeadLetter(d); {
+	case errors.Is(err, store.ErrNotFound): //набор удален
+	case err != nil:
+		log.Printf("dead letter set %d: %v", job.SetID, err)
+		m.retryLater(job) //запись не сохранилась - пусть набор останется в очереди
+		return
+	default:
+		log.Printf("set %d moved to dead letters: %s", job.SetID, reason)
+	}
+	m.ack(job)
+}
+
+// retryLater возвращает набор в очередь после неудачной выдачи с задержкой по политике retry,
+// чтобы ошибка хранилища не выбрала все выдачи набора за секунды
+func (m *Manager) retryLater(job queue.Job) {
+	if err := m.queue.Retry(job, m.retry.Backoff(job.Deliveries)); err != nil {
+		log.Printf("retry set %d: %v", job.SetID, err)
+	}
+}
+
+func (m *Manager) ack(job queue.Job) {
+	if err := m.queue.Ack(job); err != nil {
+		log.Printf("ack set %d: %v", job.SetID, err)
+	}
+}
+
+// keepVisible продлевает задачу, пока набор проверяется, чтобы очередь не выдала его другому worker.
+// Возвращаемая функция останавливает продление и отдает задачу с последним Deadline
+func (m *Manager) keepVisible(job queue.Job) func() queue.Job {
+	stop := make(chan struct{})
+	done := make(chan queue.Job, 1)
+
+	go func() {
+		defer func() { done <- job }()
+		for {
+			t := time.NewTimer(time.Until(job.Deadline) / 2)
+			select {
+			case <-stop:
+				t.Stop()
 				return
+			case <-t.C:
 			}
-			set, err := m.store.GetSet(id)
+
+			next, err := m.queue.Touch(job)
 			if err != nil {
-				log.Printf("load set %d: %v", id, err)
-				continue
+				log.Printf("touch set %d: %v", job.SetID, err)
+				return
 			}
+			job = next
+		}
+	}()
 
-			var wg sync.WaitGroup
-			for _, url := range set.Links {
-				res := set.Results[url]
-				if res != nil && (res.State == models.StateAvailable || res.State == models.StateNotAvailable) {
-					continue
-				}
+	return func() queue.Job {
+		close(stop)
+		return <-done
+	}
+}
 
-				wg.Go(func() {
-					// помечаем ссылку как processing
-					r := res
-					if r == nil {
-						r = &models.LinkResult{URL: url, State: models.StateProcessing}
-					} else {
-						r.State = models.StateProcessing
-					}
-					m.store.UpdateLinkResult(id, url, *r)
-
-					ok, detail := util.CheckURL(url)
-					result := models.LinkResult{
-						URL:       url,
-						CheckedAt: util.Now(),
-						Detail:    detail,
-					}
-					if ok {
-						result.State = models.StateAvailable
-					} else {
-						result.State = models.StateNotAvailable
-					}
-
-					if err := m.store.UpdateLinkResult(id, url, result); err != nil {
-						log.Printf("update result %s: %v", url, err)
-					}
-				})
+// checkLink проверяет ссылку, повторяя попытки по политике retry. После дедлайна набора
+// повторов нет: последний результат итоговый, ссылка, ждущая повтора, получает timeout.
+// false - проверка прервана остановкой сервиса, ссылка осталась processing до новой выдачи набора
+func (m *Manager) checkLink(ctx context.Context, id int64, url string, prev *models.LinkResult) bool {
+	attempts, ok := m.markProcessing(id, url, prev)
+	if !ok {
+		return true
+	}
+
+	for {
+		if n := len(attempts); n > 0 && !m.sleep(ctx, m.retry.Backoff(n)) {
+			if m.stopping() {
+				return false
 			}
-			wg.Wait()
+			m.save(id, url, timeoutResult(url, attempts, time.Now())) //дедлайн набора или отмена, отмену store отклонит
+			return true
 		}
+
+		start := time.Now()
+		result := util.ToLinkResult(url, m.checker.Check(ctx, url))
+		if result.State == models.StateCancelled {
+			return !m.stopping() //набор отменен, store уже пометил ссылку
+		}
+		m.observe(time.Since(start))
+		attempts = append(attempts, result.Attempts...)
+		result.Attempts = attempts
+
+		last := ctx.Err() != nil || !m.retry.ShouldRetry(result.State, len(attempts))
+		if last {
+			m.save(id, url, result)
+			return true
+		}
+		err := m.Record(id, url, result)
+		if errors.Is(err, store.ErrCancelled) {
+			return true //отмена пришла, пока шел запрос
+		}
+		if err != nil {
+			log.Printf("update result %s: %v", url, err)
+		}
+	}
+}
+
+// save записывает итоговый результат ссылки, отмененный набор store отклоняет
+func (m *Manager) save(id int64, url string, result models.LinkResult) {
+	if err := m.store.UpdateLinkResult(id, url, result); err != nil && !errors.Is(err, store.ErrCancelled) {
+		log.Printf("update result %s: %v", url, err)
+	}
+}
+
+// timeoutResult - итог ссылки, не проверенной к дедлайну набора; prev - попытки до дедлайна
+func timeoutResult(url string, prev []models.LinkAttempt, now time.Time) models.LinkResult {
+	r := models.LinkResult{URL: url, State: models.StateTimeout, CheckedAt: now, ErrorClass: util.ErrClassTimeout, Detail: "set deadline exceeded"}
+	r.Attempts = append(slices.Clone(prev), models.LinkAttempt{At: now, State: r.State, Detail: r.Detail})
+	return r
+}
+
+// Record сохраняет результат проверки ссылки. Пока по политике retry будет еще попытка,
+// ссылка остается processing с новой попыткой в Attempts: набор не завершается раньше повторов
+func (m *Manager) Record(id int64, url string, result models.LinkResult) error {
+	if m.retry.ShouldRetry(result.State, attemptsOf(&result)) {
+		result.State = models.StateProcessing
+	}
+	return m.store.UpdateLinkResult(id, url, result)
+}
+
+// observe учитывает время проверки ссылки в скользящем среднем (вес нового значения 1/8)
+func (m *Manager) observe(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.latency == 0 {
+		m.latency = d
+		return
+	}
+	m.latency += (d - m.latency) / 8
+}
+
+// markProcessing помечает ссылку как processing и возвращает попытки до этой проверки.
+// false - набор отменен
+func (m *Manager) markProcessing(id int64, url string, prev *models.LinkResult) ([]models.LinkAttempt, bool) {
+	r := models.LinkResult{URL: url}
+	if prev != nil {
+		r = *prev
+	}
+	attempts := r.Attempts
+	if len(attempts) == 0 && r.State.IsTerminal() {
+		attempts = append(attempts, models.LinkAttempt{At: r.CheckedAt, State: r.State, StatusCode: r.StatusCode, LatencyMs: r.LatencyMs, Detail: r.Detail})
+	}
+	r.State = models.StateProcessing
+	r.Attempts = attempts
+	if err := m.store.UpdateLinkResult(id, url, r); errors.Is(err, store.ErrCancelled) {
+		return nil, false
+	}
+	return attempts, true
+}
+
+// stopping - вызван Stop, новые попытки проверок не начинаются
+func (m *Manager) stopping() bool {
+	select {
+	case <-m.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// sleep ждет d, false если менеджер остановлен или ctx отменен раньше
+func (m *Manager) sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return true
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return true
+	case <-m.stop:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Track регистрирует проверку набора id: ctx отменяется вызовом Cancel(id), отменой parent,
+// дедлайном набора, остановкой менеджера (после grace) или done. Используется worker
+// и синхронной проверкой в обработчике
+func (m *Manager) Track(parent context.Context, id int64) (context.Context, func()) {
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+	)
+	if m.setTimeout > 0 {
+		ctx, cancel = context.WithTimeout(parent, m.setTimeout)
+	} else {
+		ctx, cancel = context.WithCancel(parent)
+	}
+	stopWatch := context.AfterFunc(m.ctx, cancel)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nextRun++
+	key := m.nextRun
+	if m.running[id] == nil {
+		m.running[id] = make(map[int]context.CancelFunc)
+	}
+	m.running[id][key] = cancel
+
+	return ctx, func() {
+		m.mu.Lock()
+		delete(m.running[id], key)
+		if len(m.running[id]) == 0 {
+			delete(m.running, id)
+		}
+		m.mu.Unlock()
+		stopWatch()
+		cancel()
+	}
+}
+
+// Cancel отменяет набор: store помечает непроверенные ссылки cancelled,
+// текущие запросы по набору прерываются. Завершенный набор - store.ErrFinished
+func (m *Manager) Cancel(id int64) (*models.LinkSet, error) {
+	set, err := m.store.CancelSet(id) //сначала store: поздние результаты будут отклонены
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	for _, cancel := range m.running[id] {
+		cancel()
 	}
+	m.mu.Unlock()
+	return set, nil
 }
@@ -0,0 +1,319 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/util"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/models"
+)
+
+// Remote - удаленный worker: берет аренды у центрального сервиса по HTTP (/worker/leases),
+// проверяет ссылки своим Checker с политикой повторов и отправляет результаты по мере готовности.
+// Несколько циклов аренды работают параллельно, как worker в пуле Manager
+type Remote struct {
+	server  string
+	name    string
+	token   string
+	client  *http.Client
+	checker util.Checker
+	retry   RetryPolicy
+	loops   int
+	batch   int
+	wait    time.Duration
+	grace   time.Duration
+
+	wg       sync.WaitGroup
+	idle     context.Context //ожидание аренды, отменяется в Stop
+	stopIdle context.CancelFunc
+	ctx      context.Context //проверки, отменяются после grace
+	cancel   context.CancelFunc
+	stopOnce sync.Once
+}
+
+type RemoteOption func(*Remote)
+
+// WithRemoteName - имя worker в логах центрального сервиса, по умолчанию hostname
+func WithRemoteName(name string) RemoteOption {
+	return func(r *Remote) { r.name = name }
+}
+
+// WithRemoteToken - токен api удаленных worker (LINKCHECKER_WORKER_TOKEN центрального сервиса)
+func WithRemoteToken(token string) RemoteOption {
+	return func(r *Remote) { r.token = token }
+}
+
+// WithBatch - сколько ссылок брать в одну аренду
+func WithBatch(n int) RemoteOption {
+	return func(r *Remote) { r.batch = n }
+}
+
+// WithLeaseWait - сколько ждать работу в одном запросе аренды
+func WithLeaseWait(d time.Duration) RemoteOption {
+	return func(r *Remote) { r.wait = d }
+}
+
+func WithRemoteRetry(p RetryPolicy) RemoteOption {
+	return func(r *Remote) { r.retry = p }
+}
+
+// если checker nil, используется util.HTTPChecker
+func NewRemote(server string, loops int, checker util.Checker, opts ...RemoteOption) *Remote {
+	if checker == nil {
+		checker = util.NewHTTPChecker()
+	}
+	name, _ := os.Hostname()
+
+	r := &Remote{
+		server:  strings.TrimRight(server, "/"),
+		name:    name,
+		client:  &http.Client{},
+		checker: checker,
+		retry:   DefaultRetryPolicy(),
+		loops:   max(loops, 1),
+		batch:   defaultLeaseLinks,
+		wait:    20 * time.Second,
+		grace:   defaultShutdownGrace,
+	}
+	r.idle, r.stopIdle = context.WithCancel(context.Background())
+	r.ctx, r.cancel = context.WithCancel(context.Background())
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+func (r *Remote) Run() {
+	for range r.loops {
+		r.wg.Go(r.loop)
+	}
+	r.wg.Wait()
+}
+
+// Stop перестает брать аренды и ждет текущие проверки до grace. Прерванные аренды
+// истекут на центральном сервисе, и наборы выдадут снова
+func (r *Remote) Stop() {
+	r.stopOnce.Do(r.stopIdle)
+
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+
+	t := time.NewTimer(r.grace)
+	defer t.Stop()
+	select {
+	case <-done:
+	case <-t.C:
+		log.Printf("remote worker: shutdown grace %s expired, cancelling running checks", r.grace)
+	}
+	r.cancel()
+	<-done
+}
+
+// errLeaseLost - аренда просрочена или набор отменен, результаты больше не нужны
+var errLeaseLost = errors.New("lease lost")
+
+func (r *Remote) loop() {
+	for {
+		lease, ok, err := r.lease()
+		if r.idle.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Printf("remote worker: lease: %v", err)
+			if !r.sleep(r.idle, time.Second) {
+				return
+			}
+			continue
+		}
+		if ok {
+			r.work(lease)
+		}
+	}
+}
+
+// lease запрашивает аренду, false без ошибки - работы нет
+func (r *Remote) lease() (models.Lease, bool, error) {
+	var lease models.Lease
+	body := map[string]any{"worker": r.name, "max_links": r.batch, "wait": r.wait.String()}
+	code, err := r.call(r.idle, "/worker/leases", body, &lease)
+	if err != nil || code == http.StatusNoContent {
+		return lease, false, err
+	}
+	return lease, true, nil
+}
+
+// work проверяет ссылки аренды и продлевает ее, пока идут проверки
+func (r *Remote) work(lease models.Lease) {
+	ctx, cancel := context.WithCancel(r.ctx)
+	defer cancel()
+
+	beats := make(chan struct{})
+	go func() {
+		defer close(beats)
+		r.heartbeat(ctx, cancel, lease)
+	}()
+
+	results := make(chan models.LinkResult)
+	var wg sync.WaitGroup
+	for _, link := range lease.Links {
+		wg.Go(func() {
+			if res, ok := r.check(ctx, link); ok {
+				results <- res
+			}
+		})
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	for res := range results {
+		if ctx.Err() != nil {
+			continue //аренда потеряна, дожидаемся остальных проверок
+		}
+		if err := r.submit(ctx, lease.ID, res); errors.Is(err, errLeaseLost) {
+			cancel()
+		} else if err != nil {
+			log.Printf("remote worker: submit %s: %v", res.URL, err)
+		}
+	}
+	cancel()
+	<-beats
+}
+
+// heartbeat продлевает аренду каждую треть ttl, при потере аренды отменяет проверки
+func (r *Remote) heartbeat(ctx context.Context, cancel context.CancelFunc, lease models.Lease) {
+	t := time.NewTicker(max(time.Duration(lease.TTLMs)*time.Millisecond/3, 10*time.Millisecond))
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+
+		_, err := r.call(ctx, "/worker/leases/"+lease.ID+"/heartbeat", nil, nil)
+		if errors.Is(err, errLeaseLost) {
+			log.Printf("remote worker: lease %s of set %d lost", lease.ID, lease.SetID)
+			cancel()
+			return
+		}
+		if err != nil && ctx.Err() == nil {
+			log.Printf("remote worker: heartbeat: %v", err)
+		}
+	}
+}
+
+// check проверяет ссылку, повторяя попытки по политике retry. false - проверку прервали
+func (r *Remote) check(ctx context.Context, link models.LeaseLink) (models.LinkResult, bool) {
+	var attempts []models.LinkAttempt
+	for {
+		if n := link.Attempts + len(attempts); n > 0 && !r.sleep(ctx, r.retry.Backoff(n)) {
+			return models.LinkResult{}, false
+		}
+
+		result := util.ToLinkResult(link.URL, r.checker.Check(ctx, link.URL))
+		if result.State == models.StateCancelled || ctx.Err() != nil {
+			return models.LinkResult{}, false
+		}
+		attempts = append(attempts, result.Attempts...)
+		result.Attempts = attempts
+
+		if !r.retry.ShouldRetry(result.State, link.Attempts+len(attempts)) {
+			return result, true
+		}
+	}
+}
+
+// submit отправляет результат, при сетевой ошибке или 5xx повторяет несколько раз
+func (r *Remote) submit(ctx context.Context, id string, res models.LinkResult) error {
+	body := map[string]any{"results": []models.LinkResult{res}}
+
+	var err error
+	for i := range 3 {
+		if i > 0 && !r.sleep(ctx, time.Duration(i)*time.Second) {
+			return ctx.Err()
+		}
+		var code int
+		if code, err = r.call(ctx, "/worker/leases/"+id+"/results", body, nil); err == nil || (code > 0 && code < 500) {
+			return err
+		}
+	}
+	return err
+}
+
+// call отправляет POST на центральный сервис. 404 и 410 по аренде - errLeaseLost
+func (r *Remote) call(ctx context.Context, path string, in, out any) (int, error) {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return 0, err
+		}
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.server+path, body)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if r.token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.token)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNoContent:
+		return resp.StatusCode, nil
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return resp.StatusCode, errLeaseLost
+	case resp.StatusCode >= 300:
+		var e struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&e)
+		return resp.StatusCode, fmt.Errorf("%s: %d %s", path, resp.StatusCode, e.Error)
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return resp.StatusCode, err
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+// sleep ждет d, false если ctx отменен раньше
+func (r *Remote) sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,79 @@
+package worker
+
+import (
+	"math/rand/v2"
+	"slices"
+	"time"
+
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/models"
+)
+
+// RetryPolicy - политика повторных проверок ссылки
+type RetryPolicy struct {
+	MaxAttempts int                //всего попыток, включая первую
+	BaseDelay   time.Duration      //задержка перед второй попыткой, дальше удваивается
+	MaxDelay    time.Duration      //верхняя граница задержки
+	Jitter      float64            //случайное отклонение задержки, доля от 0 до 1
+	Retryable   []models.LinkState //состояния, при которых имеет смысл повторить
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   time.Second,
+		MaxDelay:    30 * time.Second,
+		Jitter:      0.2,
+		Retryable: []models.LinkState{
+			models.StateTimeout,
+			models.StateConnectionRefused,
+			models.StateServerError,
+			models.StateNotAvailable,
+		},
+	}
+}
+
+// ShouldRetry - нужна ли еще попытка после attempts попыток, последняя закончилась state
+func (p RetryPolicy) ShouldRetry(state models.LinkState, attempts int) bool {
+	if attempts >= p.MaxAttempts {
+		return false
+	}
+	return slices.Contains(p.Retryable, state)
+}
+
+// Backoff - задержка перед следующей попыткой после attempts попыток
+func (p RetryPolicy) Backoff(attempts int) time.Duration {
+	if attempts < 1 || p.BaseDelay <= 0 {
+		return 0
+	}
+
+	d := p.BaseDelay
+	for i := 1; i < attempts; i++ {
+		d *= 2
+		if p.MaxDelay > 0 && d >= p.MaxDelay {
+			break
+		}
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+
+	if p.Jitter > 0 {
+		delta := float64(d) * p.Jitter
+		d += time.Duration(delta * (2*rand.Float64() - 1))
+	}
+	return d
+}
+
+// attemptsOf - сколько попыток уже сделано для ссылки
+func attemptsOf(res *models.LinkResult) int {
+	if res == nil {
+		return 0
+	}
+	if n := len(res.Attempts); n > 0 {
+		return n
+	}
+	if res.State.IsTerminal() {
+		return 1 //результат без истории попыток (старые файлы)
+	}
+	return 0
+}
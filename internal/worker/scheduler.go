@@ -0,0 +1,146 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/queue"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/store"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/models"
+
+	"github.com/robfig/cron/v3"
+)
+
+// MinInterval - самый короткий interval расписания: каждый запуск - новый набор в store и очереди
+const MinInterval = time.Minute
+
+// NextRun - время следующего запуска расписания после from
+func NextRun(sc models.Schedule, from time.Time) (time.Time, error) {
+	switch {
+	case sc.Interval != "" && sc.Cron != "":
+		return time.Time{}, errors.New("нужно указать interval или cron, не оба")
+	case sc.Interval != "":
+		d, err := time.ParseDuration(sc.Interval)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("bad interval: %v", err)
+		}
+		if d < MinInterval {
+			return time.Time{}, fmt.Errorf("interval должен быть не меньше %s", MinInterval)
+		}
+		return from.Add(d), nil
+	case sc.Cron != "":
+		expr, err := cron.ParseStandard(sc.Cron)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("bad cron: %v", err)
+		}
+		return expr.Next(from), nil
+	}
+	return time.Time{}, errors.New("нужно указать interval или cron")
+}
+
+// Enqueuer ставит запуск расписания в очередь (Manager)
+type Enqueuer interface {
+	Enqueue(int64, queue.Priority) error
+}
+
+// Scheduler периодически создает новый запуск набора по расписанию и ставит его в очередь Manager.
+// Каждый запуск - отдельный набор с ScheduleID, поэтому история проверок сохраняется
+type Scheduler struct {
+	store store.Store
+	mgr   Enqueuer
+	tick  time.Duration
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// tick - как часто проверять, не пора ли запустить расписание
+func NewScheduler(st store.Store, mgr Enqueuer, tick time.Duration) *Scheduler {
+	return &Scheduler{
+		store: st,
+		mgr:   mgr,
+		tick:  tick,
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+}
+
+func (s *Scheduler) Run() {
+	defer close(s.done)
+
+	t := time.NewTicker(s.tick)
+	defer t.Stop()
+
+	for {
+		s.runDue(time.Now())
+
+		select {
+		case <-s.stop:
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (s *Scheduler) Stop() {
+	s.stopOnce.Do(func() { close(s.stop) })
+	<-s.done
+}
+
+// runDue запускает расписания, время которых наступило.
+// Пропущенные за время простоя запуски не догоняются, выполняется один
+func (s *Scheduler) runDue(now time.Time) {
+	schedules, err := s.store.ListSchedules()
+	if err != nil {
+		log.Printf("list schedules: %v", err)
+		return
+	}
+
+	for _, sc := range schedules {
+		if sc.NextRunAt.After(now) {
+			continue
+		}
+
+		next, err := NextRun(sc, now)
+		if err != nil {
+			log.Printf("schedule %d: %v", sc.ID, err)
+			continue
+		}
+
+		src, err := s.store.GetSet(sc.SetID)
+		if errors.Is(err, store.ErrNotFound) { //набор удален в обход api: запуск пропускается, а не повторяется каждый тик
+			log.Printf("schedule %d: source set %d not found, skipping run", sc.ID, sc.SetID)
+			sc.NextRunAt = next
+			if err := s.store.UpdateSchedule(sc); err != nil {
+				log.Printf("schedule %d: update: %v", sc.ID, err)
+			}
+			continue
+		}
+		if err != nil {
+			log.Printf("schedule %d: load set %d: %v", sc.ID, sc.SetID, err)
+			continue
+		}
+
+		id, _, err := s.store.CreateScheduledSet(sc.ID, src.Links)
+		if err != nil {
+			log.Printf("schedule %d: create run: %v", sc.ID, err)
+			continue
+		}
+		if err := s.mgr.Enqueue(id, queue.PriorityNormal); err != nil { //запуск повторится на следующем тике
+			log.Printf("schedule %d: enqueue run %d: %v", sc.ID, id, err)
+			s.store.DeleteSet(id)
+			continue
+		}
+
+		sc.LastRunAt = now
+		sc.LastRunID = id
+		sc.NextRunAt = next
+		if err := s.store.UpdateSchedule(sc); err != nil {
+			log.Printf("schedule %d: update: %v", sc.ID, err)
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 //тип состояния ссылки
 type LinkState string
@@ -9,23 +12,245 @@ const (
 	StateUnknown      LinkState = "unknown"    //ссылка еще не проверялась
 	StateProcessing   LinkState = "processing" //в процессе проверки(worker)
 	StateAvailable    LinkState = "available"
-	StateNotAvailable LinkState = "not_available"
+	StateNotAvailable LinkState = "not_available" //прочие сетевые ошибки
+
+	StateDNSError          LinkState = "dns_error"
+	StateTimeout           LinkState = "timeout"
+	StateTLSError          LinkState = "tls_error"
+	StateConnectionRefused LinkState = "connection_refused"
+	StateClientError       LinkState = "client_error" //ответ 4xx
+	StateServerError       LinkState = "server_error" //ответ 5xx
+	StateRedirectLoop      LinkState = "redirect_loop"
+	StateCancelled         LinkState = "cancelled" //проверка отменена, ссылка не проверена
+)
+
+// IsTerminal - проверка ссылки завершена (успешно или с ошибкой). cancelled не терминальное:
+// ссылка не проверена и в историю не попадает
+func (s LinkState) IsTerminal() bool {
+	return s == StateAvailable || s.IsFailure()
+}
+
+// IsFailure - ссылка проверена и недоступна
+func (s LinkState) IsFailure() bool {
+	switch s {
+	case StateNotAvailable, StateDNSError, StateTimeout, StateTLSError,
+		StateConnectionRefused, StateClientError, StateServerError, StateRedirectLoop:
+		return true
+	}
+	return false
+}
+
+// Label - человекочитаемое название состояния для ответа api и pdf
+func (s LinkState) Label() string {
+	return strings.ReplaceAll(string(s), "_", " ")
+}
+
+//статус набора ссылок
+type SetStatus string
+
+const (
+	SetPending         SetStatus = "pending"          //ни одна ссылка еще не проверялась
+	SetProcessing      SetStatus = "processing"       //часть ссылок еще не проверена
+	SetDone            SetStatus = "done"             //все ссылки проверены и доступны
+	SetPartiallyFailed SetStatus = "partially_failed" //все ссылки проверены, часть недоступна
+	SetCancelled       SetStatus = "cancelled"        //проверка отменена
 )
 
+// IsFinished - набор больше не обрабатывается worker
+func (s SetStatus) IsFinished() bool {
+	return s == SetDone || s == SetPartiallyFailed || s == SetCancelled
+}
+
+// IsValid - известное значение статуса, для проверки фильтров api
+func (s SetStatus) IsValid() bool {
+	switch s {
+	case SetPending, SetProcessing, SetDone, SetPartiallyFailed, SetCancelled:
+		return true
+	}
+	return false
+}
+
 //результат проверки одной ссылки
 type LinkResult struct {
-	URL       string    `json:"url"`
-	State     LinkState `json:"state"`
-	CheckedAt time.Time `json:"checked_at,omitempty"`
-	Detail    string    `json:"detail,omitempty"`
+	URL        string        `json:"url"`
+	State      LinkState     `json:"state"`
+	CheckedAt  time.Time     `json:"checked_at,omitempty"`
+	Detail     string        `json:"detail,omitempty"`
+	StatusCode int           `json:"status_code,omitempty"` //http статус ответа
+	Method     string        `json:"method,omitempty"`      //HEAD или GET
+	FinalURL   string        `json:"final_url,omitempty"`   //url после редиректов
+	LatencyMs  int64         `json:"latency_ms,omitempty"`  //время ответа
+	ErrorClass string        `json:"error_class,omitempty"` //dns, timeout, tls...
+	Attempts   []LinkAttempt `json:"attempts,omitempty"`    //все попытки проверки, включая последнюю
 }
 
+// одна попытка проверки ссылки
+type LinkAttempt struct {
+	At         time.Time `json:"at"`
+	State      LinkState `json:"state"`
+	StatusCode int       `json:"status_code,omitempty"`
+	LatencyMs  int64     `json:"latency_ms,omitempty"`
+	Detail     string    `json:"detail,omitempty"`
+}
+
+// SchemaVersion - версия формата сохраненного LinkSet, при изменении формата нужна миграция в store/migrate
+const SchemaVersion = 1
+
 //набор ссылок отправленных одним запросом
 type LinkSet struct {
-	ID        int64                  `json:"id"`
-	Links     []string               `json:"links"`
-	Results   map[string]*LinkResult `json:"results"`
-	CreatedAt time.Time              `json:"created_at"`
-	UpdatedAt time.Time              `json:"updated_at"`
-	Status    string                 `json:"status"`
+	SchemaVersion int                    `json:"schema_version"`
+	ID            int64                  `json:"id"`
+	Links         []string               `json:"links"`
+	Results       map[string]*LinkResult `json:"results"`
+	CreatedAt     time.Time              `json:"created_at"`
+	UpdatedAt     time.Time              `json:"updated_at"`
+	Status        SetStatus              `json:"status"`
+	ScheduleID    int64                  `json:"schedule_id,omitempty"` //если набор создан расписанием
+}
+
+// Clone - глубокая копия набора, чтобы хранилище в памяти не отдавало наружу свои данные
+func (s *LinkSet) Clone() *LinkSet {
+	c := *s
+	c.Links = append([]string(nil), s.Links...)
+	c.Results = make(map[string]*LinkResult, len(s.Results))
+	for url, r := range s.Results {
+		rc := *r
+		rc.Attempts = append([]LinkAttempt(nil), r.Attempts...)
+		c.Results[url] = &rc
+	}
+	return &c
+}
+
+// DeriveStatus считает статус по всем ссылкам из Links, а не только по уже полученным результатам.
+// Статус cancelled не меняется
+func (s *LinkSet) DeriveStatus() SetStatus {
+	if s.Status == SetCancelled {
+		return SetCancelled
+	}
+
+	seen := make(map[string]bool, len(s.Links))
+	total, checked, terminal, failed := 0, 0, 0, 0
+	for _, url := range s.Links {
+		if seen[url] { //повторы в запросе считаем одной ссылкой
+			continue
+		}
+		seen[url] = true
+		total++
+
+		r, ok := s.Results[url]
+		if !ok {
+			continue
+		}
+		checked++
+		if r.State.IsTerminal() {
+			terminal++
+		}
+		if r.State.IsFailure() {
+			failed++
+		}
+	}
+	return StatusFromCounts(total, checked, terminal, failed)
+}
+
+// StatusFromCounts - статус набора по счетчикам: total ссылок, checked с любым результатом,
+// terminal с завершенной проверкой, failed из них недоступны. Для хранилищ, которые считают в базе
+func StatusFromCounts(total, checked, terminal, failed int) SetStatus {
+	switch {
+	case checked == 0:
+		return SetPending
+	case terminal < total:
+		return SetProcessing
+	case failed > 0:
+		return SetPartiallyFailed
+	}
+	return SetDone
+}
+
+// расписание повторных проверок набора ссылок, задается интервалом или cron выражением
+type Schedule struct {
+	ID        int64     `json:"id"`
+	SetID     int64     `json:"set_id"`             //набор, ссылки которого проверяются
+	Interval  string    `json:"interval,omitempty"` //например "5m", "1h"
+	Cron      string    `json:"cron,omitempty"`     //стандартный cron из 5 полей
+	CreatedAt time.Time `json:"created_at"`
+	NextRunAt time.Time `json:"next_run_at"`
+	LastRunAt time.Time `json:"last_run_at,omitempty"`
+	LastRunID int64     `json:"last_run_id,omitempty"` //id набора последнего запуска
+}
+
+// DeadLetter - набор, который worker не смог обработать за несколько выдач из очереди.
+// Такой набор не восстанавливается после перезапуска, пока его не вернут в очередь
+type DeadLetter struct {
+	SetID      int64     `json:"set_id"`
+	Reason     string    `json:"reason"`     //последняя ошибка обработки
+	Deliveries int       `json:"deliveries"` //сколько раз набор выдавался worker
+	FailedAt   time.Time `json:"failed_at"`
+}
+
+// Lease - аренда ссылок набора удаленным worker. Пока worker продлевает аренду, ссылки
+// не выдаются другим; просроченная аренда возвращает набор в очередь
+type Lease struct {
+	ID        string      `json:"lease_id"`
+	SetID     int64       `json:"set_id"`
+	Links     []LeaseLink `json:"links"`
+	ExpiresAt time.Time   `json:"expires_at"`
+	TTLMs     int64       `json:"ttl_ms"` //на сколько продлевает аренду каждый heartbeat
+}
+
+// LeaseLink - ссылка в аренде
+type LeaseLink struct {
+	URL      string `json:"url"`
+	Attempts int    `json:"attempts,omitempty"` //попытки проверки до аренды, для политики повторов
+}
+
+// фильтр и пагинация для списка наборов
+type SetQuery struct {
+	Status        SetStatus
+	ScheduleID    int64 //только запуски этого расписания
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	Limit         int //0 - без ограничения
+	Offset        int
+}
+
+// Match - подходит ли набор под фильтр (без учета пагинации)
+func (q SetQuery) Match(s *LinkSet) bool {
+	if q.Status != "" && s.Status != q.Status {
+		return false
+	}
+	if q.ScheduleID != 0 && s.ScheduleID != q.ScheduleID {
+		return false
+	}
+	if !q.CreatedAfter.IsZero() && !s.CreatedAt.After(q.CreatedAfter) {
+		return false
+	}
+	if !q.CreatedBefore.IsZero() && !s.CreatedAt.Before(q.CreatedBefore) {
+		return false
+	}
+	return true
+}
+
+// Page возвращает страницу из уже отфильтрованного и отсортированного списка
+func (q SetQuery) Page(sets []*LinkSet) []*LinkSet {
+	if q.Offset >= len(sets) {
+		return []*LinkSet{}
+	}
+	sets = sets[q.Offset:]
+	if q.Limit > 0 && q.Limit < len(sets) {
+		sets = sets[:q.Limit]
+	}
+	return sets
+}
+
+// статистика доступности url по истории проверок
+type URLStats struct {
+	URL           string    `json:"url"`
+	Checks        int       `json:"checks"`
+	Available     int       `json:"available"`
+	UptimePercent float64   `json:"uptime_percent"`
+	MeanLatencyMs float64   `json:"mean_latency_ms"`
+	P95LatencyMs  int64     `json:"p95_latency_ms"`
+	LastState     LinkState `json:"last_state,omitempty"`
+	LastCheckedAt time.Time `json:"last_checked_at,omitempty"`
+	LastChangeAt  time.Time `json:"last_change_at,omitempty"` //когда url перешел в текущее состояние
 }
@@ -0,0 +1,261 @@
+package worker_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/archive"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/handlers"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/queue"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/routes"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/store"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/store/memstore"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/models"
+)
+
+type recordingQueue struct {
+	mu  sync.Mutex
+	ids []int64
+}
+
+func (q *recordingQueue) Enqueue(id int64, _ queue.Priority) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.ids = append(q.ids, id)
+	return nil
+}
+
+func (q *recordingQueue) Track(ctx context.Context, id int64) (context.Context, func()) {
+	return ctx, func() {}
+}
+
+func (q *recordingQueue) Record(int64, string, models.LinkResult) error {
+	return nil
+}
+
+func (q *recordingQueue) Cancel(id int64) (*models.LinkSet, error) {
+	return nil, store.ErrNotFound
+}
+
+const adminToken = "admin-secret"
+
+// adminDo отправляет запрос к api /admin/* с токеном администратора
+func adminDo(method, url string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+	return http.DefaultClient.Do(req)
+}
+
+// переносит наборы и расписания между двумя FileStore через /admin/export и /admin/import
+func TestExportImport(t *testing.T) {
+	src, err := store.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := checked(t, src, 0)
+	sc, _ := src.CreateSchedule(models.Schedule{SetID: source, Interval: "1h"})
+	run := checked(t, src, sc.ID)
+	pending, _, _ := src.CreateSet([]string{"b.com"})
+
+	srcSrv := httptest.NewServer(routes.NewRouter(handlers.NewHandler(src, &recordingQueue{}, nil, handlers.WithAdminToken(adminToken))))
+	defer srcSrv.Close()
+
+	resp, err := http.Get(srcSrv.URL + "/admin/export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("export without admin token: expected 401, got %d", resp.StatusCode)
+	}
+	resp, err = adminDo(http.MethodGet, srcSrv.URL+"/admin/export", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/gzip" {
+		t.Fatalf("export: unexpected response %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	dst, err := store.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	local := checked(t, dst, 0) //занимает id 1
+	queue := &recordingQueue{}
+	dstSrv := httptest.NewServer(routes.NewRouter(handlers.NewHandler(dst, queue, nil, handlers.WithAdminToken(adminToken))))
+	defer dstSrv.Close()
+
+	//испорченный архив отклоняется целиком
+	resp, _ = adminDo(http.MethodPost, dstSrv.URL+"/admin/import", bytes.NewReader(tamper(t, data)))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("tampered archive: expected 400, got %d", resp.StatusCode)
+	}
+	if _, total, _ := dst.ListPage(models.SetQuery{}); total != 1 {
+		t.Fatalf("tampered archive must not change the store, got %d sets", total)
+	}
+
+	//архив больше лимитов распаковки отклоняется, не дочитываясь до конца
+	for _, lim := range []archive.ImportOption{archive.WithLimits(2, 0), archive.WithLimits(0, 100)} {
+		if _, err := archive.Import(bytes.NewReader(data), dst, lim); !errors.Is(err, archive.ErrInvalid) {
+			t.Errorf("archive over limits: expected ErrInvalid, got %v", err)
+		}
+	}
+
+	resp, err = adminDo(http.MethodPost, dstSrv.URL+"/admin/import", bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report archive.ImportReport
+	json.NewDecoder(resp.Body).Decode(&report)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || report.Sets != 3 || report.Schedules != 1 {
+		t.Fatalf("import: unexpected response %d %+v", resp.StatusCode, report)
+	}
+
+	newSource := report.Renumbered[source]
+	if newSource == 0 || newSource == local {
+		t.Fatalf("colliding set %d must get a new id, got %v", source, report.Renumbered)
+	}
+	if _, ok := report.Renumbered[pending]; ok {
+		t.Errorf("free id %d must be kept", pending)
+	}
+	if len(queue.ids) != 1 || queue.ids[0] != pending {
+		t.Errorf("unfinished set must be enqueued, got %v", queue.ids)
+	}
+
+	schedules, _ := dst.ListSchedules()
+	if len(schedules) != 1 || schedules[0].SetID != newSource {
+		t.Fatalf("schedule must point to renumbered source %d: %+v", newSource, schedules)
+	}
+	runs, _, _ := dst.ListPage(models.SetQuery{ScheduleID: schedules[0].ID})
+	if len(runs) != 1 || runs[0].Results["a.com"] == nil || runs[0].Status != models.SetDone {
+		t.Errorf("run %d must be imported under schedule %d: %+v", run, schedules[0].ID, runs)
+	}
+	if s, _ := dst.GetSet(local); s == nil || len(s.Results) != 1 {
+		t.Errorf("existing set changed by import: %+v", s)
+	}
+}
+
+// tamper меняет содержимое набора в архиве, не обновляя manifest.json
+func tamper(t *testing.T, data []byte) []byte {
+	t.Helper()
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+
+	var out bytes.Buffer
+	gw := gzip.NewWriter(&out)
+	tw := tar.NewWriter(gw)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(tr)
+		if strings.HasPrefix(hdr.Name, "sets/") {
+			b = bytes.Replace(b, []byte("a.com"), []byte("x.com"), 1)
+		}
+		hdr.Size = int64(len(b))
+		tw.WriteHeader(hdr)
+		tw.Write(b)
+	}
+	tw.Close()
+	gw.Close()
+	return out.Bytes()
+}
+
+// buildArchive собирает архив из files и manifest.json к ним
+func buildArchive(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var out bytes.Buffer
+	gw := gzip.NewWriter(&out)
+	tw := tar.NewWriter(gw)
+
+	manifest := archive.Manifest{Version: archive.Version}
+	add := func(name string, b []byte) {
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(b)), Typeflag: tar.TypeReg})
+		tw.Write(b)
+	}
+	for name, body := range files {
+		sum := sha256.Sum256([]byte(body))
+		manifest.Files = append(manifest.Files, archive.FileEntry{Name: name, Size: int64(len(body)), SHA256: hex.EncodeToString(sum[:])})
+		add(name, []byte(body))
+	}
+	b, _ := json.Marshal(manifest)
+	add("manifest.json", b)
+	tw.Close()
+	gw.Close()
+	return out.Bytes()
+}
+
+// набор с пустым результатом ссылки отклоняется при проверке архива, а не падает при записи
+func TestImportNilResult(t *testing.T) {
+	data := buildArchive(t, map[string]string{
+		"meta.json":   `{"version": 1, "sets": 1, "schedules": 0}`,
+		"sets/1.json": `{"id": 1, "links": ["a.com"], "results": {"a.com": null}}`,
+	})
+	st := memstore.New()
+	if _, err := archive.Import(bytes.NewReader(data), st); !errors.Is(err, archive.ErrInvalid) {
+		t.Fatalf("expected ErrInvalid, got %v", err)
+	}
+	if _, total, _ := st.ListPage(models.SetQuery{}); total != 0 {
+		t.Errorf("invalid archive must not change the store, got %d sets", total)
+	}
+}
+
+// failingStore отказывает в записи набора после limit успешных импортов
+type failingStore struct {
+	store.Store
+	limit int
+}
+
+func (f *failingStore) ImportSet(s *models.LinkSet) (int64, error) {
+	if f.limit == 0 {
+		return 0, errors.New("disk full")
+	}
+	f.limit--
+	return f.Store.ImportSet(s)
+}
+
+// ошибка записи посреди импорта не оставляет часть архива в хранилище
+func TestImportRollback(t *testing.T) {
+	data := buildArchive(t, map[string]string{
+		"meta.json":        `{"version": 1, "sets": 2, "schedules": 1}`,
+		"sets/1.json":      `{"id": 1, "links": ["a.com"]}`,
+		"sets/2.json":      `{"id": 2, "links": ["b.com"], "schedule_id": 1}`,
+		"schedules/1.json": `{"id": 1, "set_id": 1, "interval": "1h"}`,
+	})
+	st := memstore.New()
+	if _, err := archive.Import(bytes.NewReader(data), &failingStore{Store: st, limit: 1}); err == nil {
+		t.Fatal("expected import error")
+	}
+	if _, total, _ := st.ListPage(models.SetQuery{}); total != 0 {
+		t.Errorf("failed import must be rolled back, got %d sets", total)
+	}
+	if schedules, _ := st.ListSchedules(); len(schedules) != 0 {
+		t.Errorf("failed import must be rolled back, got %d schedules", len(schedules))
+	}
+}
@@ -0,0 +1,159 @@
+package worker_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/handlers"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/routes"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/store/memstore"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/util"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/worker"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/models"
+)
+
+// slow.* ссылки проверяются, пока проверку не отменят
+func blockingChecker(interrupted *atomic.Int32) util.Checker {
+	return util.CheckerFunc(func(ctx context.Context, url string) util.CheckResult {
+		if !strings.Contains(url, "slow") {
+			return util.CheckResult{OK: true, Detail: "ok"}
+		}
+		select {
+		case <-ctx.Done():
+			interrupted.Add(1)
+			return util.CheckResult{State: models.StateCancelled, ErrorClass: util.ErrClassCancelled}
+		case <-time.After(5 * time.Second):
+			return util.CheckResult{OK: true, Detail: "ok"}
+		}
+	})
+}
+
+func postCancel(t *testing.T, srvURL string, id string) (int, models.LinkSet) {
+	t.Helper()
+	resp, err := http.Post(srvURL+"/sets/"+id+"/cancel", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var set models.LinkSet
+	json.NewDecoder(resp.Body).Decode(&set)
+	return resp.StatusCode, set
+}
+
+func TestCancelAsyncSet(t *testing.T) {
+	st := memstore.New()
+	var interrupted atomic.Int32
+	checker := blockingChecker(&interrupted)
+
+	mgr := worker.NewManager(st, 1, checker, worker.WithRetryPolicy(worker.RetryPolicy{MaxAttempts: 1}))
+	go mgr.Run()
+	defer mgr.Stop()
+
+	srv := httptest.NewServer(routes.NewRouter(handlers.NewHandler(st, mgr, checker)))
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/sets", "application/json",
+		strings.NewReader(`{"links": ["fast.com", "slow1.com", "slow2.com"], "async": true}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	waitFor(t, func() bool {
+		s, _ := st.GetSet(1)
+		return s.Results["fast.com"] != nil && s.Results["fast.com"].State == models.StateAvailable &&
+			s.Results["slow2.com"] != nil
+	})
+
+	code, set := postCancel(t, srv.URL, "1")
+	if code != http.StatusOK || set.Status != models.SetCancelled {
+		t.Fatalf("cancel: expected 200 cancelled, got %d %s", code, set.Status)
+	}
+	if set.Results["fast.com"].State != models.StateAvailable || set.Results["slow1.com"].State != models.StateCancelled {
+		t.Errorf("unexpected results after cancel: %+v", set.Results)
+	}
+
+	waitFor(t, func() bool { return interrupted.Load() == 2 }) //оба запроса прерваны, а не досчитаны
+	if s, _ := st.GetSet(1); s.Results["slow2.com"].State != models.StateCancelled {
+		t.Errorf("interrupted check overwrote cancelled link: %+v", s.Results["slow2.com"])
+	}
+
+	if code, _ := postCancel(t, srv.URL, "1"); code != http.StatusOK {
+		t.Errorf("repeated cancel: expected 200, got %d", code)
+	}
+	if code, _ := postCancel(t, srv.URL, "99"); code != http.StatusNotFound {
+		t.Errorf("unknown set: expected 404, got %d", code)
+	}
+}
+
+// отмена прерывает и синхронную проверку, запрос отвечает сразу
+func TestCancelSyncRequest(t *testing.T) {
+	st := memstore.New()
+	var interrupted atomic.Int32
+	checker := blockingChecker(&interrupted)
+
+	mgr := worker.NewManager(st, 1, checker)
+	go mgr.Run()
+	defer mgr.Stop()
+
+	srv := httptest.NewServer(routes.NewRouter(handlers.NewHandler(st, mgr, checker)))
+	defer srv.Close()
+
+	type reply struct {
+		code    int
+		results map[string]models.LinkResult
+	}
+	replies := make(chan reply, 1)
+	go func() {
+		resp, err := http.Post(srv.URL+"/", "application/json", strings.NewReader(`{"links": ["slow.com"]}`))
+		if err != nil {
+			replies <- reply{}
+			return
+		}
+		defer resp.Body.Close()
+		var body struct {
+			Results map[string]models.LinkResult `json:"results"`
+		}
+		json.NewDecoder(resp.Body).Decode(&body)
+		replies <- reply{resp.StatusCode, body.Results}
+	}()
+
+	waitFor(t, func() bool { _, err := st.GetSet(1); return err == nil })
+	time.Sleep(20 * time.Millisecond)
+	if code, _ := postCancel(t, srv.URL, "1"); code != http.StatusOK {
+		t.Fatalf("cancel: expected 200, got %d", code)
+	}
+
+	select {
+	case r := <-replies:
+		if r.code != http.StatusOK || r.results["slow.com"].State != models.StateCancelled {
+			t.Errorf("expected cancelled result in response, got %d %+v", r.code, r.results)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("sync request was not interrupted by cancel")
+	}
+
+	//завершенный набор отменить нельзя
+	resp, _ := http.Post(srv.URL+"/", "application/json", strings.NewReader(`{"links": ["fast.com"]}`))
+	resp.Body.Close()
+	if code, _ := postCancel(t, srv.URL, "2"); code != http.StatusConflict {
+		t.Errorf("finished set: expected 409, got %d", code)
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
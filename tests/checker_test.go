@@ -0,0 +1,150 @@
+package worker_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/util"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/models"
+)
+
+// проверяет, что HTTPChecker возвращает код ответа, метод, итоговый url после редиректа и состояние ошибки
+func TestHTTPCheckerOutcome(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/new", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/new", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("/missing", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	mux.HandleFunc("/broken", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	checker := util.NewHTTPChecker()
+
+	res := checker.Check(context.Background(), srv.URL+"/old")
+	if !res.OK {
+		t.Fatalf("expected ok, got %+v", res)
+	}
+	if res.StatusCode != http.StatusOK || res.Method != http.MethodHead {
+		t.Errorf("expected 200 HEAD, got %d %s", res.StatusCode, res.Method)
+	}
+	if res.FinalURL != srv.URL+"/new" {
+		t.Errorf("expected final url %s, got %s", srv.URL+"/new", res.FinalURL)
+	}
+
+	res = checker.Check(context.Background(), srv.URL+"/missing")
+	if res.OK || res.StatusCode != http.StatusNotFound || res.State != models.StateClientError {
+		t.Errorf("expected 404 client_error, got %+v", res)
+	}
+
+	res = checker.Check(context.Background(), srv.URL+"/broken")
+	if res.OK || res.StatusCode != http.StatusBadGateway || res.State != models.StateServerError {
+		t.Errorf("expected 502 server_error, got %+v", res)
+	}
+
+	res = checker.Check(context.Background(), srv.URL+"/loop")
+	if res.OK || res.State != models.StateRedirectLoop {
+		t.Errorf("expected redirect_loop, got %+v", res)
+	}
+
+	srv.Close()
+	res = checker.Check(context.Background(), srv.URL+"/new")
+	if res.OK || res.State != models.StateConnectionRefused {
+		t.Errorf("expected connection_refused, got %+v", res)
+	}
+}
+
+// проверяет, что WithHostLimit не пускает к одному хосту больше maxPerHost запросов одновременно
+// и выдерживает паузу между обращениями
+func TestHostLimit(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		current = map[string]int{}
+		peak    = map[string]int{}
+		last    = map[string]time.Time{}
+		minGap  = time.Hour
+	)
+	base := util.CheckerFunc(func(_ context.Context, raw string) util.CheckResult {
+		host := strings.TrimPrefix(strings.SplitN(raw, "/", 2)[0], "www.")
+
+		mu.Lock()
+		current[host]++
+		peak[host] = max(peak[host], current[host])
+		if host == "slow.com" {
+			if prev, ok := last[host]; ok {
+				minGap = min(minGap, time.Since(prev))
+			}
+			last[host] = time.Now()
+		}
+		mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+
+		mu.Lock()
+		current[host]--
+		mu.Unlock()
+		return util.CheckResult{OK: true}
+	})
+
+	limited := util.Chain(base, util.WithHostLimit(util.NewHostLimiter(2, 0)))
+	polite := util.Chain(base, util.WithHostLimit(util.NewHostLimiter(10, 30*time.Millisecond)))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Go(func() { limited.Check(context.Background(), "example.com/page") })
+		wg.Go(func() { limited.Check(context.Background(), "www.example.com/other") })
+		wg.Go(func() { limited.Check(context.Background(), "other.com") })
+	}
+	for i := 0; i < 4; i++ {
+		wg.Go(func() { polite.Check(context.Background(), "slow.com") })
+	}
+	wg.Wait()
+
+	if peak["example.com"] > 2 {
+		t.Errorf("expected at most 2 concurrent requests to example.com, got %d", peak["example.com"])
+	}
+	if peak["other.com"] > 2 {
+		t.Errorf("expected at most 2 concurrent requests to other.com, got %d", peak["other.com"])
+	}
+	if minGap < 25*time.Millisecond {
+		t.Errorf("expected politeness delay between requests to slow.com, got %v", minGap)
+	}
+}
+
+// хосты без запросов убираются из limiter после паузы, занятые и ждущие паузы остаются
+func TestHostLimiterEviction(t *testing.T) {
+	l := util.NewHostLimiter(1, 50*time.Millisecond)
+
+	for _, host := range []string{"a.com", "b.com"} {
+		release, err := l.Acquire(context.Background(), host)
+		if err != nil {
+			t.Fatal(err)
+		}
+		release()
+	}
+	busy, _ := l.Acquire(context.Background(), "c.com")
+	if n := l.Hosts(); n != 3 {
+		t.Fatalf("hosts within their delay must be kept, got %d", n)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if n := l.Hosts(); n != 1 {
+		t.Errorf("expected only the busy host left, got %d", n)
+	}
+	busy()
+}
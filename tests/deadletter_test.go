@@ -0,0 +1,151 @@
+package worker_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/handlers"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/queue"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/routes"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/store"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/store/memstore"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/util"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/worker"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/models"
+)
+
+// поврежденный файл набора уходит в dead letter после N выдач, набор можно вернуть в очередь или удалить
+func TestDeadLetters(t *testing.T) {
+	dir := t.TempDir()
+	st, err := store.NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixed, _, _ := st.CreateSet([]string{"a.com"})
+	broken, _, _ := st.CreateSet([]string{"b.com"})
+	st.Close()
+
+	path := func(id int64) string { return filepath.Join(dir, "sets", fmt.Sprintf("%d.json", id)) }
+	orig, err := os.ReadFile(path(fixed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []int64{fixed, broken} {
+		os.WriteFile(path(id), []byte(`{"id": `), 0o644)
+	}
+
+	st, err = store.NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+
+	checker := util.CheckerFunc(func(context.Context, string) util.CheckResult {
+		return util.CheckResult{OK: true, Detail: "ok"}
+	})
+	retry := worker.RetryPolicy{MaxAttempts: 1, BaseDelay: time.Millisecond} //задержка перед повторной выдачей
+	mgr := worker.NewManager(st, 1, checker, worker.WithMaxDeliveries(3), worker.WithRetryPolicy(retry))
+	mgr.Enqueue(fixed, queue.PriorityNormal)
+	mgr.Enqueue(broken, queue.PriorityNormal)
+	go mgr.Run()
+	defer mgr.Stop()
+
+	srv := httptest.NewServer(routes.NewRouter(handlers.NewHandler(st, mgr, checker, handlers.WithAdminToken(adminToken))))
+	defer srv.Close()
+
+	waitFor(t, func() bool {
+		list, _ := st.ListDeadLetters()
+		return len(list) == 2
+	})
+
+	resp, err := adminDo(http.MethodGet, srv.URL+"/admin/dead-letters", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body struct {
+		DeadLetters []models.DeadLetter `json:"dead_letters"`
+		Total       int                 `json:"total"`
+	}
+	json.NewDecoder(resp.Body).Decode(&body)
+	resp.Body.Close()
+	if body.Total != 2 || body.DeadLetters[0].SetID != fixed || body.DeadLetters[0].Deliveries != 3 ||
+		!strings.Contains(body.DeadLetters[0].Reason, "load set") {
+		t.Fatalf("unexpected dead letters: %+v", body)
+	}
+
+	//файл починили - набор возвращается в очередь и проверяется
+	os.WriteFile(path(fixed), orig, 0o644)
+	resp, err = adminDo(http.MethodPost, srv.URL+fmt.Sprintf("/admin/dead-letters/%d/requeue", fixed), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("requeue: expected 202, got %d", resp.StatusCode)
+	}
+	waitFor(t, func() bool {
+		s, err := st.GetSet(fixed)
+		return err == nil && s.Status == models.SetDone
+	})
+
+	resp, err = adminDo(http.MethodDelete, srv.URL+fmt.Sprintf("/admin/dead-letters/%d", broken), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("discard: expected 204, got %d", resp.StatusCode)
+	}
+	if _, err := st.GetSet(broken); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("discarded set must be deleted, got %v", err)
+	}
+
+	resp, err = adminDo(http.MethodGet, srv.URL+fmt.Sprintf("/admin/dead-letters/%d", broken), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 for discarded dead letter, got %d", resp.StatusCode)
+	}
+	if list, _ := st.ListDeadLetters(); len(list) != 0 {
+		t.Errorf("expected no dead letters left, got %+v", list)
+	}
+}
+
+// остановка сервиса посреди проверки не считается неудачной выдачей: набор не уходит в dead letter
+func TestRestartsDoNotDeadLetter(t *testing.T) {
+	srv := hangingServer(t)
+	st := memstore.New()
+	path := filepath.Join(t.TempDir(), "queue.log")
+	id, _, _ := st.CreateSet([]string{srv.URL})
+
+	for i := range 4 {
+		q, err := queue.OpenFileQueue(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mgr := worker.NewManager(st, 1, util.NewHTTPChecker(),
+			worker.WithQueue(q), worker.WithMaxDeliveries(2), worker.WithShutdownGrace(10*time.Millisecond))
+		if i == 0 {
+			mgr.Enqueue(id, queue.PriorityNormal)
+		}
+		go mgr.Run()
+		waitFor(t, func() bool { return q.Stats().InFlight == 1 })
+		mgr.Stop()
+		q.Close()
+	}
+
+	if list, _ := st.ListDeadLetters(); len(list) != 0 {
+		t.Fatalf("graceful restarts must not dead-letter the set, got %+v", list)
+	}
+}
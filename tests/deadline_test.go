@@ -0,0 +1,169 @@
+package worker_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/handlers"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/queue"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/routes"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/store/memstore"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/util"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/worker"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/models"
+)
+
+// hangingServer отвечает только после отмены запроса клиентом
+func hangingServer(t *testing.T) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// дедлайн ссылки и запроса прерывает зависший запрос и дает состояние timeout
+func TestLinkDeadline(t *testing.T) {
+	srv := hangingServer(t)
+
+	checkers := map[string]util.Checker{
+		"request": util.NewHTTPChecker(util.WithRequestTimeout(50 * time.Millisecond)),
+		"link":    util.Chain(util.NewHTTPChecker(), util.WithTimeout(50*time.Millisecond)),
+	}
+	for name, checker := range checkers {
+		start := time.Now()
+		res := checker.Check(context.Background(), srv.URL)
+		if res.State != models.StateTimeout || res.ErrorClass != util.ErrClassTimeout {
+			t.Errorf("%s: expected timeout, got %+v", name, res)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("%s: check took %s", name, elapsed)
+		}
+	}
+}
+
+// по дедлайну набора непроверенные ссылки получают timeout и набор завершается,
+// в том числе когда timeout по политике retry подлежит повтору
+func TestSetDeadline(t *testing.T) {
+	srv := hangingServer(t)
+	policies := map[string]worker.RetryPolicy{
+		"no retries": {MaxAttempts: 1},
+		"default":    worker.DefaultRetryPolicy(),
+	}
+	for name, policy := range policies {
+		st := memstore.New()
+		mgr := worker.NewManager(st, 1, util.NewHTTPChecker(),
+			worker.WithRetryPolicy(policy),
+			worker.WithSetTimeout(100*time.Millisecond))
+		go mgr.Run()
+
+		id, _, _ := st.CreateSet([]string{srv.URL + "/a", srv.URL + "/b"})
+		mgr.Enqueue(id, queue.PriorityNormal)
+
+		waitFor(t, func() bool {
+			s, _ := st.GetSet(id)
+			return s.Status.IsFinished()
+		})
+		mgr.Stop()
+
+		s, _ := st.GetSet(id)
+		for _, url := range s.Links {
+			if r := s.Results[url]; r == nil || r.State != models.StateTimeout {
+				t.Errorf("%s: expected timeout for %s, got %+v", name, url, r)
+			}
+		}
+	}
+}
+
+// при остановке сервиса прерванные проверки не сохраняются, набор проверится после рестарта
+func TestShutdownCancelsChecks(t *testing.T) {
+	srv := hangingServer(t)
+	st := memstore.New()
+
+	mgr := worker.NewManager(st, 1, util.NewHTTPChecker(), worker.WithShutdownGrace(50*time.Millisecond))
+	go mgr.Run()
+
+	id, _, _ := st.CreateSet([]string{srv.URL})
+	mgr.Enqueue(id, queue.PriorityNormal)
+	waitFor(t, func() bool {
+		s, _ := st.GetSet(id)
+		return s.Results[srv.URL] != nil
+	})
+
+	start := time.Now()
+	mgr.Stop()
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("stop took %s, running check was not cancelled", elapsed)
+	}
+
+	s, _ := st.GetSet(id)
+	if s.Results[srv.URL].State != models.StateProcessing {
+		t.Errorf("cancelled check must not be saved, got %+v", s.Results[srv.URL])
+	}
+	if unfinished, _ := st.ListUnfinished(); len(unfinished) != 1 {
+		t.Errorf("set must stay unfinished, got %d", len(unfinished))
+	}
+}
+
+// клиент отключился от синхронного запроса: проверку доводит worker
+func TestClientDisconnect(t *testing.T) {
+	st := memstore.New()
+	var calls atomic.Int32
+	checker := util.CheckerFunc(func(ctx context.Context, url string) util.CheckResult {
+		if calls.Add(1) == 1 { //первая проверка висит до отключения клиента
+			<-ctx.Done()
+			return util.CheckResult{State: models.StateCancelled, ErrorClass: util.ErrClassCancelled}
+		}
+		return util.CheckResult{OK: true, Detail: "ok"}
+	})
+
+	mgr := worker.NewManager(st, 1, checker)
+	go mgr.Run()
+	defer mgr.Stop()
+
+	srv := httptest.NewServer(routes.NewRouter(handlers.NewHandler(st, mgr, checker)))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, srv.URL+"/", strings.NewReader(`{"links": ["slow.com"]}`))
+	go func() {
+		for calls.Load() == 0 {
+			time.Sleep(10 * time.Millisecond)
+		}
+		cancel()
+	}()
+	if _, err := http.DefaultClient.Do(req); err == nil {
+		t.Fatal("expected request to be cancelled")
+	}
+
+	waitFor(t, func() bool {
+		s, err := st.GetSet(1)
+		return err == nil && s.Status == models.SetDone
+	})
+}
+
+// остановка менеджера прерывает и синхронную проверку: ее ctx не зависит только от запроса
+func TestShutdownCancelsTracked(t *testing.T) {
+	mgr := worker.NewManager(memstore.New(), 1, nil, worker.WithShutdownGrace(10*time.Millisecond))
+	go mgr.Run()
+
+	ctx, done := mgr.Track(context.Background(), 1)
+	defer done()
+
+	stopped := make(chan struct{})
+	go func() {
+		mgr.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("tracked check was not cancelled by Stop")
+	}
+	<-stopped
+}
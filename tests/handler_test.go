@@ -0,0 +1,238 @@
+package worker_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/events"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/handlers"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/routes"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/store"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/store/memstore"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/util"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/worker"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/models"
+)
+
+// проверяет асинхронный режим: POST сразу отвечает 202, ссылки проверяет worker
+func TestAsyncSubmission(t *testing.T) {
+	store := memstore.New()
+	checker := util.CheckerFunc(func(_ context.Context, url string) util.CheckResult {
+		time.Sleep(50 * time.Millisecond)
+		return util.CheckResult{OK: true, Detail: "ok"}
+	})
+
+	mgr := worker.NewManager(store, 1, checker)
+	go mgr.Run()
+	defer mgr.Stop()
+
+	srv := httptest.NewServer(routes.NewRouter(handlers.NewHandler(store, mgr, checker)))
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/", "application/json",
+		strings.NewReader(`{"links": ["http://a.com", "http://b.com"], "async": true}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d", resp.StatusCode)
+	}
+	var accepted struct {
+		ID        int64  `json:"links_num"`
+		StatusURL string `json:"status_url"`
+	}
+	json.NewDecoder(resp.Body).Decode(&accepted)
+	if accepted.StatusURL == "" || resp.Header.Get("Location") != accepted.StatusURL {
+		t.Fatalf("expected status url in body and Location header, got %q / %q", accepted.StatusURL, resp.Header.Get("Location"))
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		r, err := http.Get(srv.URL + accepted.StatusURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var set models.LinkSet
+		json.NewDecoder(r.Body).Decode(&set)
+		r.Body.Close()
+
+		if set.Status == "done" {
+			if set.ID != accepted.ID || set.Results["http://a.com"].State != models.StateAvailable {
+				t.Errorf("unexpected set: %+v", set)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("set %d not done in time, status %s", accepted.ID, set.Status)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	r, _ := http.Get(srv.URL + "/sets/999")
+	r.Body.Close()
+	if r.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown set, got %d", r.StatusCode)
+	}
+}
+
+// проверяет REST ресурсы /sets поверх FileStore: создание, список с фильтром и пагинацией, удаление
+func TestSetsResource(t *testing.T) {
+	st, err := store.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	checker := util.CheckerFunc(func(_ context.Context, url string) util.CheckResult {
+		if strings.Contains(url, "down") {
+			return util.CheckResult{State: models.StateServerError, StatusCode: 500}
+		}
+		return util.CheckResult{OK: true, Detail: "ok"}
+	})
+
+	mgr := worker.NewManager(st, 1, checker)
+	go mgr.Run()
+	defer mgr.Stop()
+
+	srv := httptest.NewServer(routes.NewRouter(handlers.NewHandler(st, mgr, checker)))
+	defer srv.Close()
+
+	for _, body := range []string{
+		`{"links": ["http://a.com"]}`,
+		`{"links": ["http://b.com"]}`,
+		`{"links": ["http://c.com"]}`,
+	} {
+		resp, err := http.Post(srv.URL+"/sets", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusCreated || resp.Header.Get("Location") == "" {
+			t.Fatalf("expected 201 with Location, got %d", resp.StatusCode)
+		}
+	}
+
+	var page struct {
+		Sets  []models.LinkSet `json:"sets"`
+		Total int              `json:"total"`
+	}
+	resp, _ := http.Get(srv.URL + "/sets?status=done&limit=2&offset=1")
+	json.NewDecoder(resp.Body).Decode(&page)
+	resp.Body.Close()
+
+	if page.Total != 3 || len(page.Sets) != 2 {
+		t.Fatalf("expected 2 of 3 sets, got %d of %d", len(page.Sets), page.Total)
+	}
+	if page.Sets[0].ID != 2 || page.Sets[1].ID != 1 {
+		t.Errorf("expected newest first after offset (2, 1), got (%d, %d)", page.Sets[0].ID, page.Sets[1].ID)
+	}
+
+	req, _ := http.NewRequest(http.MethodDelete, srv.URL+"/sets/2", nil)
+	resp, _ = http.DefaultClient.Do(req)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected 204 on delete, got %d", resp.StatusCode)
+	}
+
+	resp, _ = http.Get(srv.URL + "/sets/2")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 after delete, got %d", resp.StatusCode)
+	}
+
+	resp, _ = http.DefaultClient.Do(req)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 on second delete, got %d", resp.StatusCode)
+	}
+
+	//набор-источник расписания не удаляется, пока есть расписание
+	sc, _ := st.CreateSchedule(models.Schedule{SetID: 3, Interval: "1h", NextRunAt: time.Now().Add(time.Hour)})
+	req, _ = http.NewRequest(http.MethodDelete, srv.URL+"/sets/3", nil)
+	resp, _ = http.DefaultClient.Do(req)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Fatalf("expected 409 on delete of a schedule source, got %d", resp.StatusCode)
+	}
+	st.DeleteSchedule(sc.ID)
+	resp, _ = http.DefaultClient.Do(req)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("expected 204 once the schedule is deleted, got %d", resp.StatusCode)
+	}
+}
+
+// проверяет поток событий: результаты ссылок приходят по мере проверки, в конце событие done
+func TestSetEventsStream(t *testing.T) {
+	st, err := store.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	hub := events.NewHub()
+	st.SetNotifier(hub)
+
+	release := make(chan struct{})
+	checker := util.CheckerFunc(func(_ context.Context, url string) util.CheckResult {
+		<-release
+		return util.CheckResult{OK: true, Detail: "ok"}
+	})
+
+	mgr := worker.NewManager(st, 1, checker)
+	go mgr.Run()
+	defer mgr.Stop()
+
+	srv := httptest.NewServer(routes.NewRouter(handlers.NewHandler(st, mgr, checker, handlers.WithEvents(hub))))
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/sets", "application/json",
+		strings.NewReader(`{"links": ["http://a.com", "http://b.com"], "async": true}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	stream, err := http.Get(srv.URL + resp.Header.Get("Location") + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Body.Close()
+	if ct := stream.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected text/event-stream, got %s", ct)
+	}
+	close(release)
+
+	available := map[string]bool{}
+	done := false
+	scanner := bufio.NewScanner(stream.Body)
+	for scanner.Scan() && !done {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+		var e events.Event
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &e); err != nil {
+			t.Fatal(err)
+		}
+		switch e.Type {
+		case events.TypeResult:
+			if e.Result.State == models.StateAvailable {
+				available[e.URL] = true
+			}
+		case events.TypeDone:
+			done = true
+		}
+	}
+
+	if !done {
+		t.Fatal("stream ended without done event")
+	}
+	if !available["http://a.com"] || !available["http://b.com"] {
+		t.Errorf("expected results for both links before done, got %v", available)
+	}
+}
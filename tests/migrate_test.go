@@ -0,0 +1,108 @@
+package worker_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/store"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/store/migrate"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/models"
+)
+
+func TestMigrateFixtures(t *testing.T) {
+	tests := []struct {
+		file    string
+		changed bool
+		status  models.SetStatus
+	}{
+		{"v0_unchecked_done.json", true, models.SetProcessing}, //набор ошибочно сохранен как done
+		{"v0_failed_done.json", true, models.SetPartiallyFailed},
+		{"v0_null_results.json", true, models.SetPending},
+		{"v1_current.json", false, models.SetPartiallyFailed},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.file, func(t *testing.T) {
+			b, err := os.ReadFile(filepath.Join("testdata", "migrate", tc.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, changed, err := migrate.Set(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if changed != tc.changed {
+				t.Errorf("changed: expected %v, got %v", tc.changed, changed)
+			}
+
+			var s models.LinkSet
+			if err := json.Unmarshal(out, &s); err != nil {
+				t.Fatal(err)
+			}
+			if s.SchemaVersion != models.SchemaVersion || s.Status != tc.status || s.Results == nil {
+				t.Errorf("expected version %d and status %s, got %d %s", models.SchemaVersion, tc.status, s.SchemaVersion, s.Status)
+			}
+		})
+	}
+
+	b, _ := os.ReadFile(filepath.Join("testdata", "migrate", "v99_future.json"))
+	if _, _, err := migrate.Set(b); err == nil || !strings.Contains(err.Error(), "newer") {
+		t.Errorf("newer schema must be rejected, got %v", err)
+	}
+}
+
+// старые файлы в каталоге FileStore обновляются командой migrate и при чтении
+func TestFileStoreMigration(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "sets"), 0o755)
+	for id, name := range map[string]string{"1": "v0_unchecked_done.json", "3": "v0_null_results.json", "4": "v1_current.json"} {
+		b, _ := os.ReadFile(filepath.Join("testdata", "migrate", name))
+		os.WriteFile(filepath.Join(dir, "sets", id+".json"), b, 0o644)
+	}
+	os.WriteFile(filepath.Join(dir, "meta.json"), []byte(`{"last": 4}`), 0o644)
+
+	st, err := store.NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+
+	report, err := st.Migrate(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Checked != 3 || len(report.Upgraded) != 2 || report.Upgraded[0] != 1 || report.Upgraded[1] != 3 {
+		t.Fatalf("dry run: expected sets [1 3] to upgrade, got %+v", report)
+	}
+	if b, _ := os.ReadFile(filepath.Join(dir, "sets", "1.json")); strings.Contains(string(b), "schema_version") {
+		t.Fatal("dry run must not rewrite files")
+	}
+
+	//GetSet обновляет файл сам
+	set, err := st.GetSet(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if set.Status != models.SetProcessing || set.Results["google.com"] == nil {
+		t.Errorf("unexpected migrated set: %+v", set)
+	}
+	if b, _ := os.ReadFile(filepath.Join(dir, "sets", "1.json")); !strings.Contains(string(b), `"schema_version": 1`) {
+		t.Errorf("migrated set must be saved: %s", b)
+	}
+
+	report, err = st.Migrate(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Upgraded) != 1 || report.Upgraded[0] != 3 {
+		t.Errorf("expected only set 3 left to upgrade, got %+v", report)
+	}
+
+	unfinished, _ := st.ListUnfinished()
+	if len(unfinished) != 2 {
+		t.Errorf("sets 1 and 3 must be unfinished after migration, got %d", len(unfinished))
+	}
+}
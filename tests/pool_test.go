@@ -0,0 +1,147 @@
+package worker_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/handlers"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/queue"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/routes"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/store/memstore"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/util"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/worker"
+)
+
+// пул растет и сжимается без перезапуска, лишние worker доделывают текущий набор
+func TestResizeWorkers(t *testing.T) {
+	st := memstore.New()
+	release := make(chan struct{})
+	checker := util.CheckerFunc(func(ctx context.Context, _ string) util.CheckResult {
+		select {
+		case <-release:
+		case <-ctx.Done():
+		}
+		return util.CheckResult{OK: true, Detail: "ok"}
+	})
+
+	mgr := worker.NewManager(st, 1, checker, worker.WithRetryPolicy(worker.RetryPolicy{MaxAttempts: 1}))
+	go mgr.Run()
+	enqueue := func(n int) {
+		for range n {
+			id, _, _ := st.CreateSet([]string{"a.com"})
+			mgr.Enqueue(id, queue.PriorityNormal)
+		}
+	}
+	busy := func(n int) func() bool {
+		return func() bool { return mgr.PoolStats().Busy == n }
+	}
+
+	enqueue(6)
+	waitFor(t, busy(1))
+	if err := mgr.Resize(3); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, busy(3))
+
+	mgr.Resize(1)
+	if s := mgr.PoolStats(); s.Workers != 1 || s.Busy != 3 {
+		t.Fatalf("retired workers must finish their sets, got %+v", s)
+	}
+	close(release)
+	waitFor(t, func() bool {
+		unfinished, _ := st.ListUnfinished()
+		return len(unfinished) == 0
+	})
+	waitFor(t, busy(0))
+
+	if err := mgr.Resize(0); !errors.Is(err, worker.ErrPoolSize) {
+		t.Errorf("resize to 0: expected ErrPoolSize, got %v", err)
+	}
+	mgr.Stop()
+	if err := mgr.Resize(2); !errors.Is(err, worker.ErrStopped) {
+		t.Errorf("resize after stop: expected ErrStopped, got %v", err)
+	}
+}
+
+func TestAutoscalePolicy(t *testing.T) {
+	p := worker.AutoscalePolicy{Min: 2, Max: 10, Backlog: 4, MaxLatency: time.Second}
+
+	for _, tc := range []struct {
+		name    string
+		cur     int
+		pending int
+		stats   worker.PoolStats
+		want    int
+	}{
+		{"backlog grows pool", 2, 12, worker.PoolStats{Busy: 2}, 5},
+		{"growth capped by max", 4, 100, worker.PoolStats{Busy: 4}, 10},
+		{"waiting set adds worker", 6, 1, worker.PoolStats{Busy: 6}, 7},
+		{"idle pool shrinks by one", 6, 0, worker.PoolStats{Busy: 2}, 5},
+		{"busy pool with empty queue stays", 6, 0, worker.PoolStats{Busy: 6}, 6},
+		{"slow checks shrink pool", 6, 50, worker.PoolStats{Busy: 6, AvgLatency: 2 * time.Second}, 5},
+		{"never below min", 2, 0, worker.PoolStats{}, 2},
+	} {
+		if got := p.Next(tc.cur, tc.pending, tc.stats); got != tc.want {
+			t.Errorf("%s: expected %d workers, got %d", tc.name, tc.want, got)
+		}
+	}
+}
+
+// GET и PUT /admin/workers, при включенном автоскейлинге размер руками не меняется
+func TestWorkersAPI(t *testing.T) {
+	st := memstore.New()
+	mgr := worker.NewManager(st, 2, nil)
+	go mgr.Run()
+	defer mgr.Stop()
+
+	scaler := worker.NewAutoscaler(mgr, worker.AutoscalePolicy{})
+	go scaler.Run()
+	defer scaler.Stop()
+
+	srv := httptest.NewServer(routes.NewRouter(handlers.NewHandler(st, mgr, nil, handlers.WithWorkers(mgr, scaler), handlers.WithAdminToken(adminToken))))
+	defer srv.Close()
+
+	put := func(body string) (int, map[string]any) {
+		resp, err := adminDo(http.MethodPut, srv.URL+"/admin/workers", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var out map[string]any
+		json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out
+	}
+
+	if code, out := put(`{"workers": 4}`); code != http.StatusOK || out["workers"] != float64(4) {
+		t.Fatalf("expected 200 with 4 workers, got %d %v", code, out)
+	}
+	if code, _ := put(`{"workers": 0}`); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for 0 workers, got %d", code)
+	}
+
+	scaler.SetPolicy(worker.AutoscalePolicy{Min: 1, Max: 3, Interval: 10 * time.Millisecond})
+	if code, _ := put(`{"workers": 8}`); code != http.StatusConflict {
+		t.Errorf("expected 409 while autoscaling, got %d", code)
+	}
+	waitFor(t, func() bool { return mgr.PoolStats().Workers == 1 }) //очередь пуста: пул сжимается до min
+
+	resp, err := adminDo(http.MethodGet, srv.URL+"/admin/workers", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var out struct {
+		Workers   int            `json:"workers"`
+		Autoscale map[string]any `json:"autoscale"`
+	}
+	json.NewDecoder(resp.Body).Decode(&out)
+	if out.Workers != 1 || out.Autoscale["max"] != float64(3) {
+		t.Errorf("unexpected workers report: %+v", out)
+	}
+}
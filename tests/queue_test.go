@@ -0,0 +1,275 @@
+package worker_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/handlers"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/queue"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/routes"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/store/memstore"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/util"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/worker"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/models"
+)
+
+func pop(t *testing.T, q queue.Queue) queue.Job {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	job, err := q.Pop(ctx)
+	if err != nil {
+		t.Fatalf("pop: %v", err)
+	}
+	return job
+}
+
+// проверяет ack/nack, дедупликацию, ограничение размера и повторную выдачу по visibility
+func TestQueueDelivery(t *testing.T) {
+	q := queue.NewMemQueue(queue.WithCapacity(2), queue.WithVisibility(50*time.Millisecond))
+
+	q.Push(1, queue.PriorityNormal)
+	q.Push(2, queue.PriorityNormal)
+	if err := q.Push(1, queue.PriorityNormal); err != nil {
+		t.Fatalf("duplicate push must be ignored, got %v", err)
+	}
+	if err := q.Push(3, queue.PriorityNormal); !errors.Is(err, queue.ErrFull) {
+		t.Fatalf("expected ErrFull, got %v", err)
+	}
+
+	job := pop(t, q)
+	if job.SetID != 1 || job.Deliveries != 1 {
+		t.Fatalf("expected set 1, got %+v", job)
+	}
+	q.Push(1, queue.PriorityNormal) //набор снова поставлен в очередь во время проверки
+	if err := q.Ack(job); err != nil {
+		t.Fatal(err)
+	}
+	if s := q.Stats(); s.Pending != 2 || s.InFlight != 0 {
+		t.Fatalf("set 1 must return to the queue after ack, got %+v", s)
+	}
+
+	job = pop(t, q)
+	q.Nack(job) //выдача без попытки проверки не засчитывается
+	if job = pop(t, q); job.SetID != 1 {
+		t.Fatalf("expected set 1 after set 2 was nacked, got %+v", job)
+	}
+	q.Ack(job)
+
+	//задачу не подтвердили вовремя: ее получает другой worker, старая выдача недействительна
+	stale := pop(t, q)
+	if stale.SetID != 2 || stale.Deliveries != 1 {
+		t.Fatalf("expected nacked delivery of set 2 not to count, got %+v", stale)
+	}
+	again := pop(t, q)
+	if again.SetID != 2 || again.Deliveries != 2 {
+		t.Fatalf("expected redelivery of set 2 after visibility timeout, got %+v", again)
+	}
+	if err := q.Ack(stale); !errors.Is(err, queue.ErrStale) {
+		t.Errorf("expected ErrStale for expired job, got %v", err)
+	}
+	if err := q.Ack(again); err != nil {
+		t.Errorf("ack: %v", err)
+	}
+
+	//неудачная выдача возвращается в очередь после задержки и засчитывается
+	q.Push(3, queue.PriorityNormal)
+	q.Retry(pop(t, q), 30*time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if job, err := q.Pop(ctx); err == nil {
+		t.Fatalf("retried set must wait for its delay, got %+v", job)
+	}
+	if job := pop(t, q); job.SetID != 3 || job.Deliveries != 2 {
+		t.Errorf("expected second delivery of set 3 after retry delay, got %+v", job)
+	}
+}
+
+// наборы в FileQueue переживают перезапуск, невыданные и неподтвержденные выдаются снова
+func TestFileQueueRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.log")
+
+	q, err := queue.OpenFileQueue(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !q.Fresh() {
+		t.Error("new queue must be fresh")
+	}
+	for id := int64(1); id <= 3; id++ {
+		q.Push(id, queue.PriorityNormal)
+	}
+	q.Ack(pop(t, q))
+	pop(t, q)         //набор 2 выдан, но процесс упал до Ack
+	q.Nack(pop(t, q)) //набор 3 возвращен при остановке, выдача не засчитывается
+	q.Close()
+
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	f.WriteString(`{"op":"push","id":`) //недописанная строка
+	f.Close()
+
+	q, err = queue.OpenFileQueue(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	if q.Fresh() {
+		t.Error("reopened queue must not be fresh")
+	}
+	if s := q.Stats(); s.Pending != 2 {
+		t.Fatalf("expected sets 2 and 3 pending, got %+v", s)
+	}
+	if job := pop(t, q); job.SetID != 2 || job.Deliveries != 2 {
+		t.Errorf("expected set 2 delivered again, got %+v", job)
+	}
+	if job := pop(t, q); job.SetID != 3 || job.Deliveries != 1 {
+		t.Errorf("expected set 3 with nacked delivery rolled back, got %+v", job)
+	}
+
+	//журнал сворачивается и не растет бесконечно
+	for id := int64(100); id < 2100; id++ {
+		q.Push(id, queue.PriorityNormal)
+		q.Ack(pop(t, q))
+	}
+	if fi, _ := os.Stat(path); fi.Size() > 64*1024 {
+		t.Errorf("queue log is not compacted: %d bytes", fi.Size())
+	}
+}
+
+// при заполненной очереди асинхронный запрос получает 503, набор не остается в store.
+// Синхронная проверка без места в очереди сохраняет результаты для повтора как итоговые
+func TestQueueFullBackpressure(t *testing.T) {
+	st := memstore.New()
+	checker := util.CheckerFunc(func(_ context.Context, url string) util.CheckResult {
+		return util.CheckResult{StatusCode: 503, ErrorClass: util.ErrClassHTTPStatus, State: models.StateServerError}
+	})
+	mgr := worker.NewManager(st, 1, checker, worker.WithQueue(queue.NewMemQueue(queue.WithCapacity(1))))
+
+	srv := httptest.NewServer(routes.NewRouter(handlers.NewHandler(st, mgr, checker)))
+	defer srv.Close()
+
+	post := func() *http.Response {
+		resp, err := http.Post(srv.URL+"/sets", "application/json", strings.NewReader(`{"links": ["a.com"], "async": true}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	if resp := post(); resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d", resp.StatusCode)
+	}
+	resp := post()
+	if resp.StatusCode != http.StatusServiceUnavailable || resp.Header.Get("Retry-After") == "" {
+		t.Fatalf("expected 503 with Retry-After, got %d", resp.StatusCode)
+	}
+	if _, total, _ := st.ListPage(models.SetQuery{}); total != 1 {
+		t.Errorf("rejected set must be removed, got %d sets", total)
+	}
+
+	resp, err := http.Post(srv.URL+"/", "application/json", strings.NewReader(`{"links": ["down.com"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var checked struct {
+		ID int64 `json:"links_num"`
+	}
+	json.NewDecoder(resp.Body).Decode(&checked)
+	resp.Body.Close()
+	s, err := st.GetSet(checked.ID)
+	if err != nil || !s.Status.IsFinished() || s.Results["down.com"].State != models.StateServerError {
+		t.Errorf("sync set must be finalized when it cannot be queued, got %+v %v", s, err)
+	}
+}
+
+// наборы выдаются из lane по весам 8:4:1, повторный push поднимает набор в lane выше, lane сохраняются в журнале
+func TestQueuePriorityLanes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.log")
+	q, err := queue.OpenFileQueue(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for id := int64(1); id <= 20; id++ {
+		q.Push(id, queue.PriorityBulk)
+	}
+	for id := int64(101); id <= 110; id++ {
+		q.Push(id, queue.PriorityInteractive)
+	}
+	for id := int64(201); id <= 205; id++ {
+		q.Push(id, queue.PriorityNormal)
+	}
+
+	got := map[queue.Priority]int{}
+	for range 13 {
+		job := pop(t, q)
+		got[job.Priority]++
+		q.Ack(job)
+	}
+	if got[queue.PriorityInteractive] != 8 || got[queue.PriorityNormal] != 4 || got[queue.PriorityBulk] != 1 {
+		t.Errorf("expected 8/4/1 deliveries per lane, got %v", got)
+	}
+
+	q.Push(20, queue.PriorityInteractive) //ждущий bulk набор стал срочным
+	q.Push(19, queue.PriorityBulk)        //понизить приоритет нельзя
+	lanes := q.Stats().Lanes
+	if lanes[queue.PriorityInteractive].Pending != 3 || lanes[queue.PriorityBulk].Pending != 18 {
+		t.Fatalf("unexpected lane depth after raise: %+v", lanes)
+	}
+	q.Close()
+
+	q, err = queue.OpenFileQueue(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	if reopened := q.Stats().Lanes; reopened[queue.PriorityInteractive] != lanes[queue.PriorityInteractive] ||
+		reopened[queue.PriorityBulk] != lanes[queue.PriorityBulk] {
+		t.Errorf("lanes not restored: expected %+v, got %+v", lanes, reopened)
+	}
+}
+
+// приоритет задается при создании набора, глубина lane видна в GET /admin/queue
+func TestQueuePriorityAPI(t *testing.T) {
+	st := memstore.New()
+	q := queue.NewMemQueue()
+	mgr := worker.NewManager(st, 1, nil, worker.WithQueue(q))
+
+	srv := httptest.NewServer(routes.NewRouter(handlers.NewHandler(st, mgr, nil, handlers.WithQueue(q), handlers.WithAdminToken(adminToken))))
+	defer srv.Close()
+
+	for body, code := range map[string]int{
+		`{"links": ["a.com"], "async": true, "priority": "bulk"}`:   http.StatusAccepted,
+		`{"links": ["b.com"], "async": true}`:                       http.StatusAccepted,
+		`{"links": ["c.com"], "async": true, "priority": "urgent"}`: http.StatusBadRequest,
+	} {
+		resp, err := http.Post(srv.URL+"/sets", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != code {
+			t.Errorf("%s: expected %d, got %d", body, code, resp.StatusCode)
+		}
+	}
+
+	resp, err := adminDo(http.MethodGet, srv.URL+"/admin/queue", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var stats queue.Stats
+	json.NewDecoder(resp.Body).Decode(&stats)
+	if stats.Pending != 2 || stats.Lanes[queue.PriorityBulk].Pending != 1 || stats.Lanes[queue.PriorityNormal].Pending != 1 {
+		t.Errorf("unexpected queue stats: %+v", stats)
+	}
+}
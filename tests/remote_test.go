@@ -0,0 +1,209 @@
+package worker_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/handlers"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/queue"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/routes"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/store/memstore"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/util"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/worker"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/models"
+)
+
+const workerToken = "secret"
+
+func postWorker(t *testing.T, url, token, body string, out any) int {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if out != nil {
+		json.NewDecoder(resp.Body).Decode(out)
+	}
+	return resp.StatusCode
+}
+
+// несколько удаленных worker разбирают наборы пачками, каждая ссылка проверяется один раз
+func TestRemoteWorkers(t *testing.T) {
+	st := memstore.New()
+	mgr := worker.NewManager(st, 1, nil) //Run не вызывается: ссылки проверяют только удаленные worker
+	defer mgr.Stop()
+
+	srv := httptest.NewServer(routes.NewRouter(handlers.NewHandler(st, mgr, nil, handlers.WithRemoteWorkers(mgr, workerToken))))
+	defer srv.Close()
+
+	var (
+		mu      sync.Mutex
+		checked = map[string]int{}
+		total   atomic.Int32
+	)
+	checker := util.CheckerFunc(func(_ context.Context, url string) util.CheckResult {
+		time.Sleep(5 * time.Millisecond)
+		mu.Lock()
+		checked[url]++
+		mu.Unlock()
+		total.Add(1)
+		return util.CheckResult{OK: true, Detail: "ok"}
+	})
+
+	for _, name := range []string{"eu", "us", "asia"} {
+		remote := worker.NewRemote(srv.URL, 2, checker,
+			worker.WithRemoteName(name), worker.WithRemoteToken(workerToken), worker.WithBatch(2),
+			worker.WithRemoteRetry(worker.RetryPolicy{MaxAttempts: 1}))
+		go remote.Run()
+		defer remote.Stop()
+	}
+
+	var ids []int64
+	for i := range 6 {
+		prefix := string(rune('a' + i))
+		id, _, _ := st.CreateSet([]string{prefix + "1.com", prefix + "2.com", prefix + "3.com"})
+		mgr.Enqueue(id, queue.PriorityNormal)
+		ids = append(ids, id)
+	}
+
+	waitFor(t, func() bool {
+		for _, id := range ids {
+			if s, err := st.GetSet(id); err != nil || s.Status != models.SetDone {
+				return false
+			}
+		}
+		return true
+	})
+	if total.Load() != 18 {
+		t.Errorf("expected 18 checks, got %d", total.Load())
+	}
+	for url, n := range checked {
+		if n != 1 {
+			t.Errorf("%s checked %d times", url, n)
+		}
+	}
+}
+
+// просроченная аренда возвращает набор в очередь, heartbeat ее продлевает, отмена набора прекращает аренду
+func TestLeaseExpiry(t *testing.T) {
+	st := memstore.New()
+	mgr := worker.NewManager(st, 1, nil, worker.WithLeaseTTL(100*time.Millisecond))
+	defer mgr.Stop()
+
+	srv := httptest.NewServer(routes.NewRouter(handlers.NewHandler(st, mgr, nil, handlers.WithRemoteWorkers(mgr, workerToken))))
+	defer srv.Close()
+
+	id, _, _ := st.CreateSet([]string{"a.com", "b.com", "c.com"})
+	mgr.Enqueue(id, queue.PriorityNormal)
+
+	if code := postWorker(t, srv.URL+"/worker/leases", "", `{}`, nil); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without token, got %d", code)
+	}
+	open := httptest.NewServer(routes.NewRouter(handlers.NewHandler(st, mgr, nil, handlers.WithRemoteWorkers(mgr, ""))))
+	defer open.Close()
+	if code := postWorker(t, open.URL+"/worker/leases", "", `{}`, nil); code != http.StatusNotImplemented {
+		t.Fatalf("api without configured token must stay disabled, got %d", code)
+	}
+
+	var first models.Lease
+	if code := postWorker(t, srv.URL+"/worker/leases", workerToken, `{"worker": "eu", "max_links": 2}`, &first); code != http.StatusOK {
+		t.Fatalf("lease: expected 200, got %d", code)
+	}
+	if first.SetID != id || len(first.Links) != 2 || first.Links[0].URL != "a.com" {
+		t.Fatalf("unexpected lease: %+v", first)
+	}
+
+	//пока аренду продлевают, набор никому не выдается
+	for range 3 {
+		time.Sleep(50 * time.Millisecond)
+		if code := postWorker(t, srv.URL+"/worker/leases/"+first.ID+"/heartbeat", workerToken, ``, nil); code != http.StatusOK {
+			t.Fatalf("heartbeat: expected 200, got %d", code)
+		}
+	}
+	if code := postWorker(t, srv.URL+"/worker/leases", workerToken, `{"wait": "30ms"}`, nil); code != http.StatusNoContent {
+		t.Fatalf("leased set must not be handed out, got %d", code)
+	}
+
+	results := func(urls ...string) string {
+		var rs []models.LinkResult
+		for _, u := range urls {
+			rs = append(rs, models.LinkResult{URL: u, State: models.StateAvailable, StatusCode: 200})
+		}
+		b, _ := json.Marshal(map[string]any{"results": rs})
+		return string(b)
+	}
+	if code := postWorker(t, srv.URL+"/worker/leases/"+first.ID+"/results", workerToken, results("c.com"), nil); code != http.StatusBadRequest {
+		t.Errorf("result for a link outside the lease: expected 400, got %d", code)
+	}
+	var left struct {
+		Remaining int `json:"remaining"`
+	}
+	postWorker(t, srv.URL+"/worker/leases/"+first.ID+"/results", workerToken, results("a.com"), &left)
+	if left.Remaining != 1 {
+		t.Fatalf("expected 1 link left in lease, got %d", left.Remaining)
+	}
+
+	//worker пропал: аренда истекает, набор выдается снова с непроверенными ссылками
+	var second models.Lease
+	if code := postWorker(t, srv.URL+"/worker/leases", workerToken, `{"worker": "us", "wait": "1s"}`, &second); code != http.StatusOK {
+		t.Fatalf("expected the set back after lease expiry, got %d", code)
+	}
+	if second.SetID != id || len(second.Links) != 2 || second.Links[0].URL != "b.com" || second.Links[1].URL != "c.com" {
+		t.Fatalf("unexpected second lease: %+v", second)
+	}
+	if code := postWorker(t, srv.URL+"/worker/leases/"+first.ID+"/results", workerToken, results("b.com"), nil); code != http.StatusNotFound {
+		t.Errorf("late result for expired lease: expected 404, got %d", code)
+	}
+	postWorker(t, srv.URL+"/worker/leases/"+second.ID+"/results", workerToken, results("b.com", "c.com"), &left)
+	if s, _ := st.GetSet(id); left.Remaining != 0 || s.Status != models.SetDone || s.Results["a.com"].State != models.StateAvailable {
+		t.Fatalf("set must be done after the second lease, got %+v", s)
+	}
+
+	//набор отменили во время аренды
+	cancelled, _, _ := st.CreateSet([]string{"d.com"})
+	mgr.Enqueue(cancelled, queue.PriorityNormal)
+	var third models.Lease
+	postWorker(t, srv.URL+"/worker/leases", workerToken, `{}`, &third)
+	mgr.Cancel(cancelled)
+	if code := postWorker(t, srv.URL+"/worker/leases/"+third.ID+"/heartbeat", workerToken, ``, nil); code != http.StatusGone {
+		t.Errorf("heartbeat for cancelled set: expected 410, got %d", code)
+	}
+	if s := mgr.PoolStats(); s.Leases != 0 {
+		t.Errorf("expected no leases left, got %d", s.Leases)
+	}
+}
+
+// один worker не забирает огромный набор целиком: max_links ограничен
+func TestLeaseLimit(t *testing.T) {
+	st := memstore.New()
+	mgr := worker.NewManager(st, 1, nil)
+	defer mgr.Stop()
+
+	links := make([]string, 600)
+	for i := range links {
+		links[i] = fmt.Sprintf("l%d.com", i)
+	}
+	id, _, _ := st.CreateSet(links)
+	mgr.Enqueue(id, queue.PriorityNormal)
+
+	l, err := mgr.Lease(context.Background(), "eu", 1_000_000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l.Links) != 500 {
+		t.Errorf("expected lease capped at 500 links, got %d", len(l.Links))
+	}
+}
@@ -0,0 +1,101 @@
+package worker_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/handlers"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/routes"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/store"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/store/memstore"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/models"
+)
+
+func checked(t *testing.T, st store.Store, scheduleID int64) int64 {
+	t.Helper()
+	id, _, err := st.CreateScheduledSet(scheduleID, []string{"a.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	st.UpdateLinkResult(id, "a.com", models.LinkResult{URL: "a.com", State: models.StateAvailable})
+	return id
+}
+
+func TestPurgeRetention(t *testing.T) {
+	st := memstore.New()
+
+	old := checked(t, st, 0)
+	source := checked(t, st, 0)
+	sc, _ := st.CreateSchedule(models.Schedule{SetID: source, Interval: "1h"})
+	run1 := checked(t, st, sc.ID)
+	run2 := checked(t, st, sc.ID)
+	run3 := checked(t, st, sc.ID)
+	unfinished, _, _ := st.CreateSet([]string{"b.com"})
+
+	policy := store.RetentionPolicy{MaxAge: time.Hour, KeepLastPerSchedule: 2}
+	later := time.Now().Add(2 * time.Hour)
+
+	dry, err := store.Purge(st, policy, later, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := fmt.Sprint([]int64{run1, old}) //новые первыми
+	if fmt.Sprint(dry.Removed) != want {
+		t.Fatalf("dry run: expected %s, got %v", want, dry.Removed)
+	}
+	if _, err := st.GetSet(old); err != nil {
+		t.Fatalf("dry run must not delete: %v", err)
+	}
+
+	report, err := store.Purge(st, policy, later, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(report.Removed) != want || report.Kept != 4 {
+		t.Fatalf("expected removed %s and 4 kept, got %+v", want, report)
+	}
+	for _, id := range []int64{source, run2, run3, unfinished} {
+		if _, err := st.GetSet(id); err != nil {
+			t.Errorf("set %d must be kept: %v", id, err)
+		}
+	}
+
+	//защищенные наборы входят в MaxCount, но сами не удаляются
+	report, _ = store.Purge(st, store.RetentionPolicy{MaxCount: 1}, time.Now(), false)
+	if fmt.Sprint(report.Removed) != fmt.Sprint([]int64{run3, run2}) || report.Kept != 2 {
+		t.Errorf("max count: expected [%d %d] removed, got %+v", run3, run2, report)
+	}
+}
+
+func TestPurgeEndpoint(t *testing.T) {
+	st := memstore.New()
+	id := checked(t, st, 0)
+
+	janitor := store.NewJanitor(st, store.RetentionPolicy{MaxAge: time.Nanosecond}, time.Hour)
+	h := handlers.NewHandler(st, nil, nil, handlers.WithJanitor(janitor), handlers.WithAdminToken(adminToken))
+	srv := httptest.NewServer(routes.NewRouter(h))
+	defer srv.Close()
+
+	time.Sleep(time.Millisecond)
+	for _, q := range []string{"?dry_run=true", ""} {
+		resp, err := adminDo(http.MethodPost, srv.URL+"/admin/purge"+q, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var report store.PurgeReport
+		json.NewDecoder(resp.Body).Decode(&report)
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK || len(report.Removed) != 1 || report.Removed[0] != id {
+			t.Fatalf("purge%s: unexpected response %d %+v", q, resp.StatusCode, report)
+		}
+	}
+
+	if _, err := st.GetSet(id); err == nil {
+		t.Error("set must be removed after purge")
+	}
+}
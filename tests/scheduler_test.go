@@ -0,0 +1,117 @@
+package worker_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/store"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/store/memstore"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/util"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/worker"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/models"
+)
+
+// проверяет, что расписание создает отдельный набор на каждый запуск и не перезаписывает прошлые
+func TestSchedulerRuns(t *testing.T) {
+	st, err := store.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	checker := util.CheckerFunc(func(_ context.Context, url string) util.CheckResult {
+		return util.CheckResult{OK: true, Detail: "ok"}
+	})
+
+	srcID, _, _ := st.CreateSet([]string{"http://a.com", "http://b.com"})
+	sc, err := st.CreateSchedule(models.Schedule{SetID: srcID, Interval: "1m", NextRunAt: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mgr := worker.NewManager(st, 1, checker)
+	go mgr.Run()
+	sched := worker.NewScheduler(st, mgr, 10*time.Millisecond)
+	go sched.Run()
+
+	//interval не короче минуты: второй запуск наступает, когда срок сдвинут вручную
+	waitFor(t, func() bool {
+		got, _ := st.GetSchedule(sc.ID)
+		return got.LastRunID != 0
+	})
+	first, _ := st.GetSchedule(sc.ID)
+	if first.NextRunAt.Before(first.LastRunAt.Add(time.Minute)) {
+		t.Errorf("next run must be an interval after the last: %+v", first)
+	}
+	first.NextRunAt = time.Now()
+	st.UpdateSchedule(first)
+	waitFor(t, func() bool {
+		got, _ := st.GetSchedule(sc.ID)
+		return got.LastRunID != first.LastRunID
+	})
+	waitFor(t, func() bool {
+		runs, _, _ := st.ListPage(models.SetQuery{ScheduleID: sc.ID, Status: models.SetDone})
+		return len(runs) == 2
+	})
+	sched.Stop()
+	mgr.Stop()
+
+	runs, total, err := st.ListPage(models.SetQuery{ScheduleID: sc.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 {
+		t.Fatalf("expected 2 runs, got %d", total)
+	}
+	for _, run := range runs {
+		if len(run.Links) != 2 || run.Status != "done" {
+			t.Errorf("run %d: expected 2 checked links, got %d links, status %s", run.ID, len(run.Links), run.Status)
+		}
+	}
+
+	sc, _ = st.GetSchedule(sc.ID)
+	if sc.LastRunID != runs[0].ID || !sc.NextRunAt.After(sc.LastRunAt) {
+		t.Errorf("schedule not advanced: %+v", sc)
+	}
+}
+
+// набор-источник удален в обход api: расписание пропускает запуск и ждет следующего, а не повторяет его каждый тик
+func TestSchedulerMissingSource(t *testing.T) {
+	st := memstore.New()
+	srcID, _, _ := st.CreateSet([]string{"http://a.com"})
+	sc, _ := st.CreateSchedule(models.Schedule{SetID: srcID, Interval: "1h", NextRunAt: time.Now()})
+	st.DeleteSet(srcID)
+
+	mgr := worker.NewManager(st, 1, nil)
+	defer mgr.Stop()
+	sched := worker.NewScheduler(st, mgr, 10*time.Millisecond)
+	go sched.Run()
+	defer sched.Stop()
+
+	waitFor(t, func() bool {
+		got, _ := st.GetSchedule(sc.ID)
+		return got.NextRunAt.After(time.Now().Add(30 * time.Minute))
+	})
+	if _, total, _ := st.ListPage(models.SetQuery{ScheduleID: sc.ID}); total != 0 {
+		t.Errorf("expected no runs without a source set, got %d", total)
+	}
+}
+
+func TestNextRun(t *testing.T) {
+	from := time.Date(2025, 11, 14, 10, 7, 0, 0, time.UTC)
+
+	next, err := worker.NextRun(models.Schedule{Interval: "1h"}, from)
+	if err != nil || !next.Equal(from.Add(time.Hour)) {
+		t.Errorf("interval: got %v, %v", next, err)
+	}
+
+	next, err = worker.NextRun(models.Schedule{Cron: "*/15 * * * *"}, from)
+	if err != nil || !next.Equal(time.Date(2025, 11, 14, 10, 15, 0, 0, time.UTC)) {
+		t.Errorf("cron: got %v, %v", next, err)
+	}
+
+	for _, bad := range []models.Schedule{{}, {Interval: "-1m"}, {Interval: "1ms"}, {Interval: "59s"}, {Cron: "bad"}, {Interval: "1m", Cron: "* * * * *"}} {
+		if _, err := worker.NextRun(bad, from); err == nil {
+			t.Errorf("expected error for %+v", bad)
+		}
+	}
+}
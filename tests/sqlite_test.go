@@ -0,0 +1,68 @@
+package worker_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/store"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/store/sqlitestore"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/models"
+)
+
+// проверяет основные операции SQLiteStore и что данные переживают переоткрытие базы
+func TestSQLiteStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "links.db")
+	st, err := sqlitestore.New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, _, err := st.CreateSet([]string{"a.com", "b.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	st.UpdateLinkResult(id, "a.com", models.LinkResult{URL: "a.com", State: models.StateAvailable, CheckedAt: time.Now(), LatencyMs: 10})
+	st.UpdateLinkResult(id, "b.com", models.LinkResult{URL: "b.com", State: models.StateProcessing})
+
+	unfinished, _ := st.ListUnfinished()
+	if len(unfinished) != 1 || unfinished[0].ID != id {
+		t.Fatalf("expected set %d unfinished, got %v", id, unfinished)
+	}
+
+	st.UpdateLinkResult(id, "b.com", models.LinkResult{URL: "b.com", State: models.StateTimeout, CheckedAt: time.Now()})
+	st.Close()
+
+	st, err = sqlitestore.New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+
+	set, err := st.GetSet(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if set.Status != models.SetPartiallyFailed || set.Results["b.com"].State != models.StateTimeout || len(set.Links) != 2 {
+		t.Errorf("unexpected set after reopen: %+v", set)
+	}
+
+	history, _ := st.History("a.com", time.Time{})
+	if len(history) != 1 || history[0].LatencyMs != 10 {
+		t.Errorf("expected one history entry for a.com, got %v", history)
+	}
+
+	id2, _, _ := st.CreateScheduledSet(7, []string{"c.com"})
+	page, total, _ := st.ListPage(models.SetQuery{ScheduleID: 7})
+	if total != 1 || page[0].ID != id2 || id2 <= id {
+		t.Errorf("expected scheduled set %d, got %d sets", id2, total)
+	}
+
+	if err := st.DeleteSet(id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.GetSet(id); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("expected ErrNotFound after delete, got %v", err)
+	}
+}
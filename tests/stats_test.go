@@ -0,0 +1,77 @@
+package worker_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/handlers"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/routes"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/stats"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/store"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/models"
+)
+
+func TestComputeStats(t *testing.T) {
+	base := time.Date(2025, 11, 14, 10, 0, 0, 0, time.UTC)
+	var history []models.LinkResult
+	for i := 0; i < 20; i++ {
+		state := models.StateAvailable
+		if i == 10 || i == 11 {
+			state = models.StateServerError
+		}
+		history = append(history, models.LinkResult{
+			State:     state,
+			CheckedAt: base.Add(time.Duration(i) * time.Minute),
+			LatencyMs: int64(i + 1),
+		})
+	}
+
+	st := stats.Compute("a.com", history)
+	if st.Checks != 20 || st.Available != 18 || st.UptimePercent != 90 {
+		t.Errorf("unexpected uptime: %+v", st)
+	}
+	if st.MeanLatencyMs != 10.5 || st.P95LatencyMs != 19 {
+		t.Errorf("unexpected latency: mean %v, p95 %d", st.MeanLatencyMs, st.P95LatencyMs)
+	}
+	if st.LastState != models.StateAvailable || !st.LastChangeAt.Equal(base.Add(12*time.Minute)) {
+		t.Errorf("unexpected last change: %s at %v", st.LastState, st.LastChangeAt)
+	}
+}
+
+// проверяет, что повторные проверки не перезаписывают историю и статистика доступна через api
+func TestURLStatsEndpoint(t *testing.T) {
+	st, err := store.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, state := range []models.LinkState{models.StateAvailable, models.StateTimeout, models.StateAvailable, models.StateAvailable} {
+		id, _, _ := st.CreateSet([]string{"a.com"})
+		st.UpdateLinkResult(id, "a.com", models.LinkResult{URL: "a.com", State: models.StateProcessing})
+		st.UpdateLinkResult(id, "a.com", models.LinkResult{
+			URL:       "a.com",
+			State:     state,
+			CheckedAt: time.Now().Add(time.Duration(i) * time.Second),
+			LatencyMs: 100,
+		})
+	}
+
+	srv := httptest.NewServer(routes.NewRouter(handlers.NewHandler(st, nil, nil)))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/stats?url=" + url.QueryEscape("a.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var got models.URLStats
+	json.NewDecoder(resp.Body).Decode(&got)
+	if got.Checks != 4 || got.UptimePercent != 75 || got.MeanLatencyMs != 100 {
+		t.Errorf("unexpected stats: %+v", got)
+	}
+}
@@ -0,0 +1,101 @@
+package worker_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/store"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/store/memstore"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/store/sqlitestore"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/store/storetest"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/models"
+)
+
+func TestFileStoreConformance(t *testing.T) {
+	storetest.Run(t, storetest.Backend{
+		Persistent: true,
+		Open: func(t *testing.T, dir string) store.Store {
+			st, err := store.NewFileStore(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return st
+		},
+	})
+}
+
+func TestSQLiteStoreConformance(t *testing.T) {
+	storetest.Run(t, storetest.Backend{
+		Persistent: true,
+		Open: func(t *testing.T, dir string) store.Store {
+			st, err := sqlitestore.New(filepath.Join(dir, "links.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return st
+		},
+	})
+}
+
+func TestMemStoreConformance(t *testing.T) {
+	storetest.Run(t, storetest.Backend{
+		Open: func(t *testing.T, dir string) store.Store { return memstore.New() },
+	})
+}
+
+// обновления из журнала должны пережить падение процесса без Close и недописанную строку
+func TestFileStoreJournalReplay(t *testing.T) {
+	dir := t.TempDir()
+	st, err := store.NewFileStore(dir, store.WithCompactEvery(1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, _, _ := st.CreateSet([]string{"a.com", "b.com"})
+	st.UpdateLinkResult(id, "a.com", models.LinkResult{URL: "a.com", State: models.StateAvailable, LatencyMs: 7})
+	st.UpdateLinkResult(id, "b.com", models.LinkResult{URL: "b.com", State: models.StateProcessing})
+
+	journal := filepath.Join(dir, "sets", fmt.Sprintf("%d.journal", id))
+	b, err := os.ReadFile(filepath.Join(dir, "sets", fmt.Sprintf("%d.json", id)))
+	if err != nil || strings.Contains(string(b), "available") {
+		t.Fatalf("snapshot must not be rewritten before compaction: %s", b)
+	}
+
+	//падение посреди записи следующего события, st не закрываем
+	file, err := os.OpenFile(journal, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatalf("journal not written: %v", err)
+	}
+	file.WriteString(`{"url":"b.com","result":{"state":"avail`)
+	file.Close()
+
+	st, err = store.NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+
+	set, err := st.GetSet(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := set.Results["a.com"]; r == nil || r.LatencyMs != 7 || set.Status != models.SetProcessing {
+		t.Fatalf("journal not replayed: %+v", set)
+	}
+	if _, err := os.Stat(journal); !os.IsNotExist(err) {
+		t.Errorf("journal must be compacted on open, stat err: %v", err)
+	}
+
+	//завершение набора сворачивает журнал сразу
+	st.UpdateLinkResult(id, "b.com", models.LinkResult{URL: "b.com", State: models.StateAvailable})
+	if _, err := os.Stat(journal); !os.IsNotExist(err) {
+		t.Errorf("journal must be compacted when set is finished, stat err: %v", err)
+	}
+	unfinished, _ := st.ListUnfinished()
+	if len(unfinished) != 0 {
+		t.Errorf("expected no unfinished sets, got %d", len(unfinished))
+	}
+}
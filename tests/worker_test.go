@@ -1,131 +1,46 @@
 package worker_test
 
 import (
+	"context"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/queue"
+	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/store/memstore"
 	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/util"
 	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/internal/worker"
 	"github.com/EugeneKrivoshein/14_11_2025_linkChecker/models"
 )
 
-type InMemoryStore struct {
-	mu     sync.Mutex
-	lastID int64
-	sets   map[int64]*models.LinkSet
-}
-
-func NewInMemoryStore() *InMemoryStore {
-	return &InMemoryStore{sets: make(map[int64]*models.LinkSet)}
-}
-
-func (s *InMemoryStore) CreateSet(links []string) (int64, *models.LinkSet, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.lastID++
-	id := s.lastID
-	set := &models.LinkSet{
-		ID:      id,
-		Links:   links,
-		Results: make(map[string]*models.LinkResult),
-		Status:  "processing",
-	}
-	s.sets[id] = set
-	return id, set, nil
-}
-
-func (s *InMemoryStore) GetSet(id int64) (*models.LinkSet, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	return s.sets[id], nil
-}
-
-func (s *InMemoryStore) UpdateLinkResult(id int64, url string, res models.LinkResult) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	set, ok := s.sets[id]
-	if !ok || set == nil {
-		return nil
-	}
-
-	set.Results[url] = &res
-
-	if len(set.Results) < len(set.Links) {
-		set.Status = "processing"
-		return nil
-	}
-
-	allDone := true
-	for _, r := range set.Results {
-		if r.State != models.StateAvailable && r.State != models.StateNotAvailable {
-			allDone = false
-			break
-		}
-	}
-
-	if allDone {
-		set.Status = "done"
-	}
-	return nil
-}
-
-func (s *InMemoryStore) ListUnfinished() ([]*models.LinkSet, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	var out []*models.LinkSet
-	for _, set := range s.sets {
-		if set.Status != "done" {
-			out = append(out, set)
-		}
-	}
-	return out, nil
-}
-
-func (s *InMemoryStore) ListSets(ids []int64) ([]*models.LinkSet, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	var out []*models.LinkSet
-	for _, id := range ids {
-		set, ok := s.sets[id]
-		if ok {
-			out = append(out, set)
-		}
-	}
-	return out, nil
-}
-
 // проверяет, что worker корректно: останавливается посреди работы, после рестарта продолжает выполнение с того же места
 func TestWorkerGracefulRestart(t *testing.T) {
-	store := NewInMemoryStore()
+	store := memstore.New()
 
-	origCheck := util.CheckURL
-	defer func() { util.CheckURL = origCheck }()
-
-	util.CheckURL = func(url string) (bool, string) {
+	checker := util.CheckerFunc(func(_ context.Context, url string) util.CheckResult {
 		switch url {
 		case "http://link1.com":
 			time.Sleep(300 * time.Millisecond)
-			return true, "ok"
+			return util.CheckResult{OK: true, Detail: "ok"}
 		case "http://link2.com":
-			return false, "not available"
+			return util.CheckResult{Detail: "not available"}
 		case "http://link3.com":
-			return true, "ok"
+			return util.CheckResult{OK: true, Detail: "ok"}
 		default:
-			return false, "not found"
+			return util.CheckResult{Detail: "not found"}
 		}
-	}
+	})
+
+	noRetry := worker.WithRetryPolicy(worker.RetryPolicy{MaxAttempts: 1})
 
 	id1, _, _ := store.CreateSet([]string{"http://link1.com", "http://link2.com"})
 	id2, _, _ := store.CreateSet([]string{"http://link3.com"})
 
-	mgr := worker.NewManager(store, 1)
+	mgr := worker.NewManager(store, 1, checker, noRetry)
 	go mgr.Run()
 
-	mgr.Enqueue(id1)
-	mgr.Enqueue(id2)
+	mgr.Enqueue(id1, queue.PriorityNormal)
+	mgr.Enqueue(id2, queue.PriorityNormal)
 
 	time.Sleep(100 * time.Millisecond)
 
@@ -139,7 +54,7 @@ func TestWorkerGracefulRestart(t *testing.T) {
 		t.Fatalf("expected task id2 to be unfinished, got: %v", unfinished)
 	}
 
-	mgr2 := worker.NewManager(store, 1)
+	mgr2 := worker.NewManager(store, 1, checker, noRetry)
 	go mgr2.Run()
 
 	time.Sleep(200 * time.Millisecond)
@@ -163,3 +78,87 @@ func TestWorkerGracefulRestart(t *testing.T) {
 		t.Errorf("link3 expected available, got %s", set2.Results["http://link3.com"].State)
 	}
 }
+
+// проверяет, что worker повторяет проверку по политике retry и сохраняет все попытки
+func TestWorkerRetry(t *testing.T) {
+	store := memstore.New()
+
+	var mu sync.Mutex
+	calls := map[string]int{}
+	checker := util.CheckerFunc(func(_ context.Context, url string) util.CheckResult {
+		mu.Lock()
+		calls[url]++
+		n := calls[url]
+		mu.Unlock()
+
+		switch {
+		case url == "http://flaky.com" && n < 3:
+			return util.CheckResult{State: models.StateTimeout, Detail: "timeout"}
+		case url == "http://flaky.com":
+			return util.CheckResult{OK: true, Detail: "ok"}
+		case url == "http://missing.com":
+			return util.CheckResult{State: models.StateClientError, StatusCode: 404}
+		default:
+			return util.CheckResult{State: models.StateServerError, StatusCode: 503}
+		}
+	})
+
+	id, _, _ := store.CreateSet([]string{"http://flaky.com", "http://missing.com", "http://down.com"})
+
+	policy := worker.DefaultRetryPolicy()
+	policy.BaseDelay = 10 * time.Millisecond
+	policy.Jitter = 0
+
+	mgr := worker.NewManager(store, 1, checker, worker.WithRetryPolicy(policy))
+	go mgr.Run()
+	time.Sleep(200 * time.Millisecond)
+	mgr.Stop()
+
+	set, _ := store.GetSet(id)
+
+	flaky := set.Results["http://flaky.com"]
+	if flaky.State != models.StateAvailable || len(flaky.Attempts) != 3 {
+		t.Errorf("flaky expected available after 3 attempts, got %s after %d", flaky.State, len(flaky.Attempts))
+	}
+	if h, _ := store.History("http://flaky.com", time.Time{}); len(h) != 1 || h[0].State != models.StateAvailable {
+		t.Errorf("history must keep only the final result of the check, got %+v", h)
+	}
+
+	missing := set.Results["http://missing.com"]
+	if missing.State != models.StateClientError || len(missing.Attempts) != 1 {
+		t.Errorf("client error must not be retried, got %s after %d", missing.State, len(missing.Attempts))
+	}
+
+	down := set.Results["http://down.com"]
+	if down.State != models.StateServerError || len(down.Attempts) != policy.MaxAttempts {
+		t.Errorf("down expected server_error after %d attempts, got %s after %d", policy.MaxAttempts, down.State, len(down.Attempts))
+	}
+}
+
+// пока ссылку повторяют, она остается processing и набор не завершается
+func TestWorkerRetryKeepsProcessing(t *testing.T) {
+	st := memstore.New()
+	checker := util.CheckerFunc(func(context.Context, string) util.CheckResult {
+		return util.CheckResult{State: models.StateServerError, StatusCode: 503}
+	})
+	id, _, _ := st.CreateSet([]string{"http://down.com"})
+
+	policy := worker.DefaultRetryPolicy()
+	policy.BaseDelay = time.Hour
+	mgr := worker.NewManager(st, 1, checker, worker.WithRetryPolicy(policy))
+	go mgr.Run()
+	defer mgr.Stop()
+
+	waitFor(t, func() bool {
+		s, _ := st.GetSet(id)
+		res := s.Results["http://down.com"]
+		return res != nil && len(res.Attempts) == 1
+	})
+	s, _ := st.GetSet(id)
+	if res := s.Results["http://down.com"]; res.State != models.StateProcessing || res.StatusCode != 503 {
+		t.Errorf("link waiting for retry must stay processing, got %s (%d)", res.State, res.StatusCode)
+	}
+	if s.Status != models.SetProcessing {
+		t.Errorf("set must stay processing until the last attempt, got %s", s.Status)
+	}
+}